@@ -2,17 +2,23 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/stuttgart-things/machinery-status-collector/internal/informer"
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tlsconfig"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -35,8 +41,6 @@ func runInformer(cmd *cobra.Command, args []string) error {
 	required := []string{
 		"CLUSTER_NAME",
 		"COLLECTOR_URL",
-		"CLAIM_GROUP",
-		"CLAIM_RESOURCE",
 	}
 	var missing []string
 	for _, key := range required {
@@ -50,15 +54,29 @@ func runInformer(cmd *cobra.Command, args []string) error {
 
 	clusterName := os.Getenv("CLUSTER_NAME")
 	collectorURL := os.Getenv("COLLECTOR_URL")
-	claimGroup := os.Getenv("CLAIM_GROUP")
-	claimResource := os.Getenv("CLAIM_RESOURCE")
+	claimNamespace := os.Getenv("CLAIM_NAMESPACE")
 
-	claimVersion := os.Getenv("CLAIM_VERSION")
-	if claimVersion == "" {
-		claimVersion = "v1alpha1"
+	discoverXRDs, err := envBool("CLAIM_DISCOVERY", false)
+	if err != nil {
+		return err
 	}
 
-	claimNamespace := os.Getenv("CLAIM_NAMESPACE")
+	batchWindowStr := os.Getenv("COLLECTOR_BATCH_WINDOW")
+	if batchWindowStr == "" {
+		batchWindowStr = "500ms"
+	}
+	batchWindow, err := time.ParseDuration(batchWindowStr)
+	if err != nil {
+		return fmt.Errorf("invalid COLLECTOR_BATCH_WINDOW: %w", err)
+	}
+
+	gvrs, err := claimGVRs()
+	if err != nil {
+		return err
+	}
+	if len(gvrs) == 0 && !discoverXRDs {
+		return fmt.Errorf("no claim GVRs configured: set CLAIM_GVRS, CLAIM_GVRS_FILE, CLAIM_GROUP and CLAIM_RESOURCE, or enable CLAIM_DISCOVERY")
+	}
 
 	// Build Kubernetes client (in-cluster or kubeconfig).
 	dynamicClient, err := buildDynamicClient()
@@ -66,21 +84,72 @@ func runInformer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("build kubernetes client: %w", err)
 	}
 
-	gvr := schema.GroupVersionResource{
-		Group:    claimGroup,
-		Version:  claimVersion,
-		Resource: claimResource,
+	opts := []informer.ClaimWatcherOption{
+		informer.WithXRDDiscovery(discoverXRDs),
+		informer.WithBatchWindow(batchWindow),
+	}
+	tlsCfg, err := collectorTLSConfig()
+	if err != nil {
+		return err
+	}
+	if tlsCfg != nil {
+		opts = append(opts, informer.WithTLSConfig(tlsCfg))
+	}
+	switch {
+	case os.Getenv("COLLECTOR_TOKEN_FILE") != "":
+		opts = append(opts, informer.WithBearerTokenFile(os.Getenv("COLLECTOR_TOKEN_FILE")))
+	case os.Getenv("COLLECTOR_TOKEN") != "":
+		opts = append(opts, informer.WithBearerToken(os.Getenv("COLLECTOR_TOKEN")))
+	}
+	secret, err := hmacSecret()
+	if err != nil {
+		return err
+	}
+	if secret != nil {
+		opts = append(opts, informer.WithRequestSigning(secret))
+	}
+	if dir := os.Getenv("OUTBOX_DIR"); dir != "" {
+		outbox, err := informer.NewOutbox(dir)
+		if err != nil {
+			return fmt.Errorf("open outbox: %w", err)
+		}
+		log.Printf("spooling undelivered statuses to %s", dir)
+		opts = append(opts, informer.WithOutbox(outbox))
+	}
+
+	shutdownTracing, err := setupTracing("machinery-status-informer")
+	if err != nil {
+		return err
 	}
+	defer shutdownTracing()
 
-	watcher := informer.NewClaimWatcher(dynamicClient, collectorURL, clusterName, gvr, claimNamespace)
+	watcher := informer.NewClaimWatcher(dynamicClient, collectorURL, clusterName, gvrs, claimNamespace, opts...)
+	if err := metrics.RegisterDeliveryQueue(watcher.PendingDeliveries); err != nil {
+		return fmt.Errorf("register queue metrics: %w", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	errCh := make(chan error, 1)
+
+	// Optional listener for probes and metrics.
+	var healthSrv *http.Server
+	if port := os.Getenv("INFORMER_PORT"); port != "" {
+		healthSrv = &http.Server{Addr: ":" + port, Handler: watcher.Handler()}
+		go func() {
+			log.Printf("health and metrics listening on %s", healthSrv.Addr)
+			if err := healthSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("health server: %w", err)
+			}
+		}()
+	}
+
+	watcherDone := make(chan struct{})
 	go func() {
-		log.Printf("informer starting: cluster=%s gvr=%s/%s/%s namespace=%q",
-			clusterName, claimGroup, claimVersion, claimResource, claimNamespace)
+		defer close(watcherDone)
+		log.Printf("informer starting: cluster=%s gvrs=%v discovery=%t namespace=%q",
+			clusterName, gvrs, discoverXRDs, claimNamespace)
 		if err := watcher.Start(ctx); err != nil && ctx.Err() == nil {
 			errCh <- err
 		}
@@ -97,23 +166,129 @@ func runInformer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("informer error: %w", err)
 	}
 
+	// Wait for the watcher to spool undelivered claims to the outbox.
 	cancel()
+	<-watcherDone
+	if healthSrv != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		if err := healthSrv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("shutdown error: %w", err)
+		}
+	}
 	log.Println("informer stopped")
 	return nil
 }
 
-func buildDynamicClient() (dynamic.Interface, error) {
-	config, err := rest.InClusterConfig()
+// claimGVRs collects the claim GVRs to watch from CLAIM_GVRS_FILE, CLAIM_GVRS
+// and the single-GVR CLAIM_GROUP/CLAIM_VERSION/CLAIM_RESOURCE variables.
+// Duplicates are dropped.
+func claimGVRs() ([]schema.GroupVersionResource, error) {
+	var gvrs []schema.GroupVersionResource
+
+	if path := os.Getenv("CLAIM_GVRS_FILE"); path != "" {
+		fromFile, err := informer.LoadGVRFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CLAIM_GVRS_FILE: %w", err)
+		}
+		gvrs = append(gvrs, fromFile...)
+	}
+
+	fromEnv, err := informer.ParseGVRList(os.Getenv("CLAIM_GVRS"))
 	if err != nil {
-		// Fall back to kubeconfig.
-		kubeconfig := os.Getenv("KUBECONFIG")
-		if kubeconfig == "" {
-			kubeconfig = os.Getenv("HOME") + "/.kube/config"
+		return nil, fmt.Errorf("invalid CLAIM_GVRS: %w", err)
+	}
+	gvrs = append(gvrs, fromEnv...)
+
+	claimGroup := os.Getenv("CLAIM_GROUP")
+	claimResource := os.Getenv("CLAIM_RESOURCE")
+	if claimGroup != "" || claimResource != "" {
+		if claimGroup == "" || claimResource == "" {
+			return nil, fmt.Errorf("CLAIM_GROUP and CLAIM_RESOURCE must be set together")
 		}
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			return nil, fmt.Errorf("load kubeconfig: %w", err)
+		claimVersion := os.Getenv("CLAIM_VERSION")
+		if claimVersion == "" {
+			claimVersion = "v1alpha1"
+		}
+		gvrs = append(gvrs, schema.GroupVersionResource{
+			Group:    claimGroup,
+			Version:  claimVersion,
+			Resource: claimResource,
+		})
+	}
+
+	seen := make(map[schema.GroupVersionResource]bool, len(gvrs))
+	unique := gvrs[:0]
+	for _, gvr := range gvrs {
+		if !seen[gvr] {
+			seen[gvr] = true
+			unique = append(unique, gvr)
 		}
 	}
+	return unique, nil
+}
+
+// collectorTLSConfig builds the TLS configuration for COLLECTOR_URL from
+// COLLECTOR_CA_FILE, COLLECTOR_CLIENT_CERT_FILE and COLLECTOR_CLIENT_KEY_FILE.
+// It returns nil if none of them is set.
+func collectorTLSConfig() (*tls.Config, error) {
+	caFile := os.Getenv("COLLECTOR_CA_FILE")
+	certFile := os.Getenv("COLLECTOR_CLIENT_CERT_FILE")
+	keyFile := os.Getenv("COLLECTOR_CLIENT_KEY_FILE")
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	cfg, err := tlsconfig.NewClientConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collector TLS configuration: %w", err)
+	}
+	if certFile != "" {
+		log.Printf("using client certificate %s for collector requests", certFile)
+	}
+	return cfg, nil
+}
+
+func buildDynamicClient() (dynamic.Interface, error) {
+	config, err := kubeConfig()
+	if err != nil {
+		return nil, err
+	}
 	return dynamic.NewForConfig(config)
 }
+
+func buildKubeClient() (kubernetes.Interface, error) {
+	config, err := kubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// kubeConfig returns the in-cluster configuration, falling back to KUBECONFIG.
+func kubeConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err == nil {
+		return config, nil
+	}
+	kubeconfig := os.Getenv("KUBECONFIG")
+	if kubeconfig == "" {
+		kubeconfig = os.Getenv("HOME") + "/.kube/config"
+	}
+	config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("load kubeconfig: %w", err)
+	}
+	return config, nil
+}
+
+// podNamespace returns POD_NAMESPACE, falling back to the namespace of the
+// service account when running in a pod.
+func podNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return "default"
+}
@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -15,6 +19,14 @@ import (
 	"github.com/stuttgart-things/machinery-status-collector/internal/api"
 	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
 	"github.com/stuttgart-things/machinery-status-collector/internal/git"
+	"github.com/stuttgart-things/machinery-status-collector/internal/health"
+	"github.com/stuttgart-things/machinery-status-collector/internal/leader"
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+	"github.com/stuttgart-things/machinery-status-collector/internal/signing"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tlsconfig"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 var serverCmd = &cobra.Command{
@@ -32,12 +44,30 @@ func init() {
 }
 
 func runServer(cmd *cobra.Command, args []string) error {
-	// Required environment variables.
-	required := []string{
-		"GITHUB_TOKEN",
-		"REGISTRY_REPO_OWNER",
-		"REGISTRY_REPO_NAME",
-		"REGISTRY_FILE_PATH",
+	provider := os.Getenv("REGISTRY_PROVIDER")
+	if provider == "" {
+		provider = "github"
+	}
+
+	// Required environment variables. A plain git remote is addressed by
+	// URL instead of owner and name.
+	required := []string{"REGISTRY_FILE_PATH"}
+	switch provider {
+	case "github":
+		required = append(required, "REGISTRY_REPO_OWNER", "REGISTRY_REPO_NAME")
+		if os.Getenv("GITHUB_APP_ID") != "" {
+			required = append(required, "GITHUB_APP_INSTALLATION_ID", "GITHUB_APP_PRIVATE_KEY_FILE")
+		} else {
+			required = append(required, "GITHUB_TOKEN")
+		}
+	case "gitlab":
+		required = append(required, "REGISTRY_REPO_OWNER", "REGISTRY_REPO_NAME", "GITLAB_TOKEN")
+	case "gitea":
+		required = append(required, "REGISTRY_REPO_OWNER", "REGISTRY_REPO_NAME", "GITEA_URL", "GITEA_TOKEN")
+	case "git":
+		required = append(required, "GIT_URL")
+	default:
+		return fmt.Errorf("invalid REGISTRY_PROVIDER %q (must be github, gitlab, gitea or git)", provider)
 	}
 	var missing []string
 	for _, key := range required {
@@ -49,7 +79,6 @@ func runServer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("missing required environment variables:\n  %s", strings.Join(missing, "\n  "))
 	}
 
-	token := os.Getenv("GITHUB_TOKEN")
 	owner := os.Getenv("REGISTRY_REPO_OWNER")
 	repo := os.Getenv("REGISTRY_REPO_NAME")
 	filePath := os.Getenv("REGISTRY_FILE_PATH")
@@ -74,25 +103,169 @@ func runServer(cmd *cobra.Command, args []string) error {
 		baseBranch = "main"
 	}
 
+	statusBranch := os.Getenv("REGISTRY_STATUS_BRANCH")
+	if statusBranch == "" {
+		statusBranch = collector.DefaultBranchName
+	}
+
+	skipUnchanged, err := envBool("COLLECTOR_SKIP_UNCHANGED", true)
+	if err != nil {
+		return err
+	}
+
+	autoRegister, err := envBool("COLLECTOR_AUTO_REGISTER", false)
+	if err != nil {
+		return err
+	}
+
+	deletePolicyStr := os.Getenv("COLLECTOR_DELETE_POLICY")
+	if deletePolicyStr == "" {
+		deletePolicyStr = string(collector.DeletePolicyMark)
+	}
+	deletePolicy, err := collector.ParseDeletePolicy(deletePolicyStr)
+	if err != nil {
+		return fmt.Errorf("invalid COLLECTOR_DELETE_POLICY: %w", err)
+	}
+
+	livenessIntervals := 3
+	if v := os.Getenv("COLLECTOR_LIVENESS_INTERVALS"); v != "" {
+		livenessIntervals, err = strconv.Atoi(v)
+		if err != nil || livenessIntervals < 1 {
+			return fmt.Errorf("invalid COLLECTOR_LIVENESS_INTERVALS: must be a positive integer, got %q", v)
+		}
+	}
+
+	shutdownTracing, err := setupTracing("machinery-status-collector")
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing()
+
+	elector, err := leaderElector()
+	if err != nil {
+		return fmt.Errorf("invalid leader election configuration: %w", err)
+	}
+	// Followers only ingest; without a shared store the leader never sees
+	// their updates. Processes competing for a lock file share the bolt
+	// database instead.
+	_, fileLock := elector.(*leader.FileElector)
+	backend := os.Getenv("COLLECTOR_STORE_BACKEND")
+	if elector != nil && backend != "configmap" && !(backend == "bolt" && fileLock) {
+		return fmt.Errorf("leader election requires COLLECTOR_STORE_BACKEND=configmap, or bolt outside a cluster, otherwise updates received by other replicas are never reconciled")
+	}
+
 	// Create dependencies.
-	store := collector.NewStatusStore()
-	gitClient := git.NewGitHubClient(token, owner, repo)
-	rec := collector.NewReconciler(store, gitClient, interval, filePath, baseBranch)
-	apiServer := api.NewServer(store, Version, Commit)
+	store, err := newStatusStore(fileLock)
+	if err != nil {
+		return fmt.Errorf("create status store: %w", err)
+	}
+	defer store.Close()
+	if err := metrics.RegisterStore(store.Stats); err != nil {
+		return fmt.Errorf("register store metrics: %w", err)
+	}
+
+	gitClient, err := newGitClient(provider, owner, repo, baseBranch)
+	if err != nil {
+		return fmt.Errorf("create %s client: %w", provider, err)
+	}
+	rec := collector.NewReconciler(store, gitClient, interval, filePath, baseBranch,
+		collector.WithBranchName(statusBranch),
+		collector.WithSkipUnchanged(skipUnchanged),
+		collector.WithAutoRegister(autoRegister),
+		collector.WithDeletePolicy(deletePolicy),
+		collector.WithLivenessIntervals(livenessIntervals),
+	)
+
+	// The Git provider is probed at most once a minute to stay clear of
+	// rate limits.
+	ready := &health.Checks{}
+	ready.Add(provider, health.Cached(rec.CheckGit, time.Minute))
+	ready.Add("registry", rec.CheckRegistry)
+	ready.Add("store", store.Check)
+	live := &health.Checks{}
+	live.Add("reconciler", func(ctx context.Context) error {
+		// Only the leader runs the reconcile loop.
+		if elector != nil && !elector.IsLeader() {
+			return nil
+		}
+		return rec.CheckHeartbeat(ctx)
+	})
+
+	tlsCfg, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	var auths []api.Authenticator
+	if path := os.Getenv("COLLECTOR_AUTH_TOKENS_FILE"); path != "" {
+		auth, err := api.LoadTokenFile(path)
+		if err != nil {
+			return fmt.Errorf("invalid COLLECTOR_AUTH_TOKENS_FILE: %w", err)
+		}
+		log.Printf("token authentication enabled for status ingestion")
+		auths = append(auths, auth)
+	}
+	if os.Getenv("COLLECTOR_TLS_CLIENT_CA_FILE") != "" {
+		log.Printf("client certificate authentication enabled for status ingestion")
+		auths = append(auths, api.CertificateAuthenticator{})
+	}
+	serverOpts := []api.ServerOption{
+		api.WithReadinessChecks(ready),
+		api.WithLivenessChecks(live),
+	}
+	if len(auths) > 0 {
+		serverOpts = append(serverOpts, api.WithAuthenticator(api.AnyAuthenticator(auths...)))
+	}
+
+	secret, err := hmacSecret()
+	if err != nil {
+		return err
+	}
+	if secret != nil {
+		maxAgeStr := os.Getenv("COLLECTOR_SIGNATURE_MAX_AGE")
+		if maxAgeStr == "" {
+			maxAgeStr = "5m"
+		}
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil {
+			return fmt.Errorf("invalid COLLECTOR_SIGNATURE_MAX_AGE: %w", err)
+		}
+		log.Printf("request signature verification enabled (max age %s)", maxAge)
+		serverOpts = append(serverOpts, api.WithRequestSigning(secret, maxAge))
+	}
+	apiServer := api.NewServer(store, Version, Commit, serverOpts...)
 
-	// Start reconciler in background.
+	// Start reconciler in background, on the leader only if elected.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go rec.Start(ctx)
+	recDone := make(chan struct{})
+	electorErr := make(chan error, 1)
+	go func() {
+		defer close(recDone)
+		if elector != nil {
+			if err := elector.Run(ctx, rec.Start); err != nil {
+				electorErr <- err
+			}
+			return
+		}
+		rec.Start(ctx)
+	}()
 
 	// Start HTTP server.
 	addr := ":" + port
-	srv := &http.Server{Addr: addr, Handler: apiServer.Handler}
+	srv := &http.Server{Addr: addr, Handler: apiServer.Handler, TLSConfig: tlsCfg}
 
 	errCh := make(chan error, 1)
 	go func() {
-		log.Printf("server listening on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsCfg != nil {
+			log.Printf("server listening on %s (TLS)", addr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("server listening on %s", addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
@@ -106,6 +279,10 @@ func runServer(cmd *cobra.Command, args []string) error {
 		log.Printf("received signal %v, shutting down", sig)
 	case err := <-errCh:
 		return fmt.Errorf("server error: %w", err)
+	case err := <-electorErr:
+		// Without a running elector no replica may ever reconcile, so
+		// exit instead of reporting healthy.
+		return fmt.Errorf("leader election: %w", err)
 	}
 
 	// Graceful shutdown.
@@ -115,7 +292,302 @@ func runServer(cmd *cobra.Command, args []string) error {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("shutdown error: %w", err)
 	}
+	// Let the elector release its lease so another replica takes over
+	// without waiting for it to expire.
+	select {
+	case <-recDone:
+	case <-shutdownCtx.Done():
+	}
 
 	log.Println("server stopped")
 	return nil
 }
+
+// serverTLSConfig builds the HTTPS configuration from COLLECTOR_TLS_CERT_FILE,
+// COLLECTOR_TLS_KEY_FILE and the optional COLLECTOR_TLS_CLIENT_CA_FILE. It
+// returns nil if the server should serve plain HTTP.
+func serverTLSConfig() (*tls.Config, error) {
+	certFile := os.Getenv("COLLECTOR_TLS_CERT_FILE")
+	keyFile := os.Getenv("COLLECTOR_TLS_KEY_FILE")
+	clientCAFile := os.Getenv("COLLECTOR_TLS_CLIENT_CA_FILE")
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, fmt.Errorf("COLLECTOR_TLS_CLIENT_CA_FILE requires COLLECTOR_TLS_CERT_FILE and COLLECTOR_TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("COLLECTOR_TLS_CERT_FILE and COLLECTOR_TLS_KEY_FILE must be set together")
+	}
+	cfg, err := tlsconfig.NewServerConfig(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// newGitClient builds the client for the registry repository on provider,
+// which has been validated by the caller.
+func newGitClient(provider, owner, repo, baseBranch string) (collector.GitClient, error) {
+	switch provider {
+	case "gitlab":
+		instanceURL := os.Getenv("GITLAB_URL")
+		if instanceURL == "" {
+			instanceURL = git.DefaultGitLabURL
+		}
+		log.Printf("using GitLab project %s/%s on %s", owner, repo, instanceURL)
+		return git.NewGitLabClient(os.Getenv("GITLAB_TOKEN"), instanceURL, owner, repo), nil
+	case "gitea":
+		instanceURL := os.Getenv("GITEA_URL")
+		log.Printf("using Gitea repository %s/%s on %s", owner, repo, instanceURL)
+		return git.NewGiteaClient(os.Getenv("GITEA_TOKEN"), instanceURL, owner, repo), nil
+	case "git":
+		return newPlainGitClient(baseBranch)
+	default:
+		opts, err := githubOptions()
+		if err != nil {
+			return nil, err
+		}
+		if appID := os.Getenv("GITHUB_APP_ID"); appID != "" {
+			return newGitHubAppClient(appID, owner, repo, opts)
+		}
+		return git.NewGitHubClient(os.Getenv("GITHUB_TOKEN"), owner, repo, opts...), nil
+	}
+}
+
+// githubOptions configures the GitHub client for a GitHub Enterprise Server
+// from GITHUB_URL, GITHUB_CA_FILE and GITHUB_PROXY_URL.
+func githubOptions() ([]git.GitHubOption, error) {
+	var opts []git.GitHubOption
+	if apiURL := os.Getenv("GITHUB_URL"); apiURL != "" {
+		u, err := url.Parse(apiURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid GITHUB_URL %q: must be an absolute URL", apiURL)
+		}
+		log.Printf("using GitHub API at %s", apiURL)
+		opts = append(opts, git.WithGitHubURL(apiURL))
+	}
+	if caFile := os.Getenv("GITHUB_CA_FILE"); caFile != "" {
+		cfg, err := tlsconfig.NewClientConfig("", "", caFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GITHUB_CA_FILE: %w", err)
+		}
+		opts = append(opts, git.WithGitHubTLSConfig(cfg))
+	}
+	if proxy := os.Getenv("GITHUB_PROXY_URL"); proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid GITHUB_PROXY_URL %q: must be an absolute URL", proxy)
+		}
+		opts = append(opts, git.WithGitHubProxy(u))
+	}
+	return opts, nil
+}
+
+// newGitHubAppClient builds a GitHub client that authenticates as the
+// installation GITHUB_APP_INSTALLATION_ID of the app appID.
+func newGitHubAppClient(appID, owner, repo string, opts []git.GitHubOption) (collector.GitClient, error) {
+	installationID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_INSTALLATION_ID"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GITHUB_APP_INSTALLATION_ID: %w", err)
+	}
+	keyFile := os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE")
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read GITHUB_APP_PRIVATE_KEY_FILE: %w", err)
+	}
+	client, err := git.NewGitHubAppClient(appID, installationID, key, owner, repo, opts...)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("authenticating to GitHub as app %s (installation %d)", appID, installationID)
+	return client, nil
+}
+
+// newPlainGitClient builds the go-git client for the remote at GIT_URL,
+// authenticating with GIT_SSH_KEY_FILE or GIT_USERNAME and GIT_PASSWORD.
+func newPlainGitClient(baseBranch string) (collector.GitClient, error) {
+	remoteURL := os.Getenv("GIT_URL")
+	workDir := os.Getenv("GIT_WORK_DIR")
+	if workDir == "" {
+		workDir = filepath.Join(os.TempDir(), "machinery-status-collector-registry")
+	}
+
+	var opts []git.PlainGitOption
+	switch {
+	case os.Getenv("GIT_SSH_KEY_FILE") != "":
+		opts = append(opts, git.WithSSHKeyFile(os.Getenv("GIT_SSH_KEY_FILE"), os.Getenv("GIT_SSH_KEY_PASSWORD")))
+	case os.Getenv("GIT_USERNAME") != "" || os.Getenv("GIT_PASSWORD") != "":
+		opts = append(opts, git.WithBasicAuth(os.Getenv("GIT_USERNAME"), os.Getenv("GIT_PASSWORD")))
+	}
+	if name, email := os.Getenv("GIT_AUTHOR_NAME"), os.Getenv("GIT_AUTHOR_EMAIL"); name != "" || email != "" {
+		if name == "" {
+			name = git.DefaultAuthorName
+		}
+		if email == "" {
+			email = git.DefaultAuthorEmail
+		}
+		opts = append(opts, git.WithAuthor(name, email))
+	}
+	directPush, err := envBool("GIT_DIRECT_PUSH", false)
+	if err != nil {
+		return nil, err
+	}
+	if directPush {
+		opts = append(opts, git.WithDirectPush(baseBranch))
+	}
+
+	client, err := git.NewPlainGitClient(remoteURL, workDir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if directPush {
+		log.Printf("using git remote %s (work directory %s), pushing to %s", remoteURL, workDir, baseBranch)
+	} else {
+		log.Printf("using git remote %s (work directory %s)", remoteURL, workDir)
+	}
+	return client, nil
+}
+
+// newStatusStore builds the StatusStore with the backend selected by
+// COLLECTOR_STORE_BACKEND. If shared is set, the bolt database is opened per
+// operation so that other processes on the host can use it too.
+func newStatusStore(shared bool) (*collector.StatusStore, error) {
+	backend := os.Getenv("COLLECTOR_STORE_BACKEND")
+	if backend == "" {
+		backend = "memory"
+	}
+
+	switch backend {
+	case "memory":
+		return collector.NewStatusStore(), nil
+	case "bolt":
+		path := os.Getenv("COLLECTOR_STORE_PATH")
+		if path == "" {
+			path = "status.db"
+		}
+		if shared {
+			b, err := collector.NewSharedBoltBackend(path)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("using shared bolt store backend at %s", path)
+			return collector.NewStatusStoreWithBackend(b), nil
+		}
+		b, err := collector.NewBoltBackend(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("using bolt store backend at %s", path)
+		return collector.NewStatusStoreWithBackend(b), nil
+	case "configmap":
+		name := os.Getenv("COLLECTOR_STORE_CONFIGMAP")
+		if name == "" {
+			name = "machinery-status-collector-store"
+		}
+		client, err := buildKubeClient()
+		if err != nil {
+			return nil, fmt.Errorf("build kubernetes client: %w", err)
+		}
+		namespace := podNamespace()
+		b, err := collector.NewConfigMapBackend(client, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("using configmap store backend %s/%s", namespace, name)
+		return collector.NewStatusStoreWithBackend(b), nil
+	default:
+		return nil, fmt.Errorf("invalid COLLECTOR_STORE_BACKEND %q (must be memory, bolt or configmap)", backend)
+	}
+}
+
+// leaderElector returns the Elector selected by COLLECTOR_LEADER_ELECTION, or
+// nil if leader election is disabled. In a pod replicas compete for a Lease;
+// elsewhere a lock file is used.
+func leaderElector() (leader.Elector, error) {
+	enabled, err := envBool("COLLECTOR_LEADER_ELECTION", false)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		path := os.Getenv("COLLECTOR_LEADER_ELECTION_LOCK_FILE")
+		if path == "" {
+			path = filepath.Join(os.TempDir(), "machinery-status-collector.lock")
+		}
+		log.Printf("not running in a cluster, using lock file %s for leader election", path)
+		return leader.NewFileElector(path), nil
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("build kubernetes client: %w", err)
+	}
+	name := os.Getenv("COLLECTOR_LEADER_ELECTION_LEASE")
+	if name == "" {
+		name = "machinery-status-collector"
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("determine leader election identity: %w", err)
+		}
+	}
+	namespace := podNamespace()
+	log.Printf("using lease %s/%s for leader election as %s", namespace, name, identity)
+	return leader.NewLeaseElector(client, namespace, name, identity), nil
+}
+
+// envBool parses a boolean environment variable, returning def when unset.
+func envBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// hmacSecret returns the request signing secret from COLLECTOR_HMAC_SECRET_FILE
+// or COLLECTOR_HMAC_SECRET, or nil if signing is disabled. It is shared by the
+// server and the informer.
+func hmacSecret() ([]byte, error) {
+	if path := os.Getenv("COLLECTOR_HMAC_SECRET_FILE"); path != "" {
+		secret, err := signing.LoadSecret(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid COLLECTOR_HMAC_SECRET_FILE: %w", err)
+		}
+		return secret, nil
+	}
+	if secret := os.Getenv("COLLECTOR_HMAC_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	return nil, nil
+}
+
+// setupTracing configures span export from OTEL_TRACES_EXPORTER. The returned
+// function flushes pending spans.
+func setupTracing(serviceName string) (func(), error) {
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	shutdown, err := tracing.Setup(context.Background(), serviceName, exporter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTEL_TRACES_EXPORTER: %w", err)
+	}
+	if exporter != "" && exporter != tracing.ExporterNone {
+		log.Printf("exporting traces via %s", exporter)
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Printf("flush traces: %v", err)
+		}
+	}, nil
+}
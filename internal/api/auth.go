@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnauthenticated is returned by an Authenticator when a request carries no
+// or unknown credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Identity describes the authenticated caller of a write request.
+type Identity struct {
+	// Cluster is the only cluster the caller may report statuses for.
+	Cluster string
+}
+
+// Authenticator resolves the Identity of an incoming request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// StaticTokenAuthenticator authenticates bearer tokens against a fixed set of
+// tokens, each bound to one cluster.
+type StaticTokenAuthenticator struct {
+	// clusters maps the SHA-256 of a token to its cluster, so lookups do not
+	// compare secrets byte by byte.
+	clusters map[[sha256.Size]byte]string
+}
+
+// NewStaticTokenAuthenticator creates an authenticator from a token to cluster
+// mapping.
+func NewStaticTokenAuthenticator(tokens map[string]string) *StaticTokenAuthenticator {
+	a := &StaticTokenAuthenticator{clusters: make(map[[sha256.Size]byte]string, len(tokens))}
+	for token, cluster := range tokens {
+		a.clusters[sha256.Sum256([]byte(token))] = cluster
+	}
+	return a
+}
+
+type tokenFile struct {
+	Tokens []struct {
+		Cluster string `yaml:"cluster"`
+		Token   string `yaml:"token"`
+	} `yaml:"tokens"`
+}
+
+// LoadTokenFile reads cluster tokens from a YAML file, e.g. a mounted Secret:
+//
+//	tokens:
+//	  - cluster: cluster-01
+//	    token: s3cr3t
+//
+// A cluster may be listed several times to allow token rotation.
+func LoadTokenFile(path string) (*StaticTokenAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read token file: %w", err)
+	}
+	var f tokenFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse token file: %w", err)
+	}
+
+	tokens := make(map[string]string, len(f.Tokens))
+	for i, t := range f.Tokens {
+		if t.Cluster == "" || t.Token == "" {
+			return nil, fmt.Errorf("token file entry %d: cluster and token are required", i)
+		}
+		if other, ok := tokens[t.Token]; ok && other != t.Cluster {
+			return nil, fmt.Errorf("token file entry %d: token already bound to cluster %q", i, other)
+		}
+		tokens[t.Token] = t.Cluster
+	}
+	return NewStaticTokenAuthenticator(tokens), nil
+}
+
+// Authenticate implements Authenticator.
+func (a *StaticTokenAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Identity{}, ErrUnauthenticated
+	}
+	cluster, ok := a.clusters[sha256.Sum256([]byte(token))]
+	if !ok {
+		return Identity{}, ErrUnauthenticated
+	}
+	return Identity{Cluster: cluster}, nil
+}
+
+// CertificateAuthenticator authenticates requests by their verified TLS
+// client certificate. The cluster is the certificate's common name or, if
+// that is empty, its first DNS SAN.
+type CertificateAuthenticator struct{}
+
+// Authenticate implements Authenticator.
+func (CertificateAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, ErrUnauthenticated
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	cluster := cert.Subject.CommonName
+	if cluster == "" && len(cert.DNSNames) > 0 {
+		cluster = cert.DNSNames[0]
+	}
+	if cluster == "" {
+		return Identity{}, ErrUnauthenticated
+	}
+	return Identity{Cluster: cluster}, nil
+}
+
+// AnyAuthenticator returns an Authenticator that accepts a request if one of
+// auths does, trying them in order.
+func AnyAuthenticator(auths ...Authenticator) Authenticator {
+	return anyAuthenticator(auths)
+}
+
+type anyAuthenticator []Authenticator
+
+func (a anyAuthenticator) Authenticate(r *http.Request) (Identity, error) {
+	for _, auth := range a {
+		if id, err := auth.Authenticate(r); err == nil {
+			return id, nil
+		}
+	}
+	return Identity{}, ErrUnauthenticated
+}
+
+type identityKey struct{}
+
+func contextWithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the Identity stored by the auth middleware.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// authMiddleware rejects requests the Authenticator cannot authenticate and
+// stores the caller's Identity in the request context.
+func authMiddleware(auth Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := auth.Authenticate(r)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(contextWithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+// clusterAllowed reports whether the caller of r may write statuses for
+// cluster. Requests without an Identity are allowed, which is the case when
+// no Authenticator is configured.
+func clusterAllowed(r *http.Request, cluster string) bool {
+	id, ok := IdentityFromContext(r.Context())
+	return !ok || id.Cluster == cluster
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
+)
+
+func newAuthTestServer() *Server {
+	auth := NewStaticTokenAuthenticator(map[string]string{
+		"token-a": "cluster-a",
+		"token-b": "cluster-b",
+	})
+	return NewServer(collector.NewStatusStore(), "v0.1.0-test", "abc1234", WithAuthenticator(auth))
+}
+
+func TestAuth_PostStatus(t *testing.T) {
+	srv := newAuthTestServer()
+
+	cases := []struct {
+		name  string
+		token string
+		code  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"unknown token", "nope", http.StatusUnauthorized},
+		{"token of other cluster", "token-b", http.StatusForbidden},
+		{"matching token", "token-a", http.StatusCreated},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"cluster":"cluster-a","claimRef":"my/claim","statusMessage":"ready"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tc.code {
+				t.Fatalf("expected %d, got %d", tc.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuth_DeleteOtherCluster(t *testing.T) {
+	srv := newAuthTestServer()
+	srv.store.Put("cluster-a", "my/claim", "ready")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/status/cluster-a/my/claim", nil)
+	req.Header.Set("Authorization", "Bearer token-b")
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+	if e, _, _ := srv.store.Get("cluster-a", "", "my/claim"); e.Deleted {
+		t.Fatal("expected claim of another cluster to stay untouched")
+	}
+}
+
+func TestAuth_BatchForbiddenItems(t *testing.T) {
+	srv := newAuthTestServer()
+
+	body := `[
+		{"cluster":"cluster-a","claimRef":"ns/one","statusMessage":"ready"},
+		{"cluster":"cluster-b","claimRef":"ns/two","statusMessage":"ready"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status:batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token-a")
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	var resp batchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Results[0].Status != batchStatusCreated || resp.Results[1].Status != batchStatusForbidden {
+		t.Fatalf("unexpected results: %+v", resp.Results)
+	}
+	if _, ok, _ := srv.store.Get("cluster-b", "", "ns/two"); ok {
+		t.Fatal("expected item of another cluster not to be stored")
+	}
+}
+
+func TestAuth_ReadsStayOpen(t *testing.T) {
+	srv := newAuthTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestLoadTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	content := `tokens:
+  - cluster: cluster-a
+    token: old-token
+  - cluster: cluster-a
+    token: new-token
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	auth, err := LoadTokenFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, token := range []string{"old-token", "new-token"} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		id, err := auth.Authenticate(req)
+		if err != nil || id.Cluster != "cluster-a" {
+			t.Errorf("expected %q to authenticate as cluster-a, got %+v (%v)", token, id, err)
+		}
+	}
+
+	dup := `tokens:
+  - cluster: cluster-a
+    token: shared
+  - cluster: cluster-b
+    token: shared
+`
+	if err := os.WriteFile(path, []byte(dup), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := LoadTokenFile(path); err == nil {
+		t.Fatal("expected error for a token bound to two clusters")
+	}
+}
+
+func TestCertificateAuthenticator(t *testing.T) {
+	cases := []struct {
+		name    string
+		cert    *x509.Certificate
+		cluster string
+		wantErr bool
+	}{
+		{"no certificate", nil, "", true},
+		{"common name", &x509.Certificate{Subject: pkix.Name{CommonName: "cluster-a"}}, "cluster-a", false},
+		{"dns san", &x509.Certificate{DNSNames: []string{"cluster-b", "other"}}, "cluster-b", false},
+		{"no name", &x509.Certificate{}, "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.TLS = &tls.ConnectionState{}
+			if tc.cert != nil {
+				req.TLS.VerifiedChains = [][]*x509.Certificate{{tc.cert}}
+			}
+			id, err := CertificateAuthenticator{}.Authenticate(req)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", id)
+				}
+				return
+			}
+			if err != nil || id.Cluster != tc.cluster {
+				t.Fatalf("expected cluster %q, got %+v (%v)", tc.cluster, id, err)
+			}
+		})
+	}
+}
+
+func TestAnyAuthenticator(t *testing.T) {
+	auth := AnyAuthenticator(
+		CertificateAuthenticator{},
+		NewStaticTokenAuthenticator(map[string]string{"token-a": "cluster-a"}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer token-a")
+	if id, err := auth.Authenticate(req); err != nil || id.Cluster != "cluster-a" {
+		t.Fatalf("expected fallback to token authenticator, got %+v (%v)", id, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	if _, err := auth.Authenticate(req); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+}
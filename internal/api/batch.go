@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxBatchSize limits the number of items accepted by a single batch request.
+const maxBatchSize = 1000
+
+// Per-item outcomes reported by the batch endpoint. Invalid and forbidden
+// items will never succeed as sent; failed items may be retried.
+const (
+	batchStatusCreated   = "created"
+	batchStatusDeleted   = "deleted"
+	batchStatusInvalid   = "invalid"
+	batchStatusForbidden = "forbidden"
+	batchStatusFailed    = "failed"
+)
+
+// batchItem is a status update or, with Deleted set, a deletion report.
+type batchItem struct {
+	statusRequest
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+type batchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Results  []batchResult `json:"results"`
+}
+
+func (s *Server) handlePostStatusBatch(w http.ResponseWriter, r *http.Request) {
+	var items []batchItem
+	if !decodeBody(w, r, &items) {
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, `{"error":"at least one item is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(items) > maxBatchSize {
+		http.Error(w, `{"error":"too many items"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Valid items are stored together in a single backend write.
+	resp := batchResponse{Results: make([]batchResult, len(items))}
+	var (
+		entries []collector.StatusEntry
+		stored  []int
+	)
+	for i, item := range items {
+		if item.Cluster != "" && !clusterAllowed(r, item.Cluster) {
+			resp.Results[i] = batchResult{Status: batchStatusForbidden, Error: "not permitted to report for this cluster"}
+			continue
+		}
+		entry, result := batchEntry(item)
+		resp.Results[i] = result
+		if result.Status != batchStatusInvalid {
+			entries = append(entries, entry)
+			stored = append(stored, i)
+		}
+	}
+	if len(entries) > 0 {
+		if err := s.putBatch(r.Context(), entries); err != nil {
+			slog.Error("store batch", "error", err)
+			msg := "failed to store batch"
+			if errors.Is(err, collector.ErrStoreFull) {
+				msg = "status store is full"
+			}
+			for _, i := range stored {
+				resp.Results[i] = batchResult{Status: batchStatusFailed, Error: msg}
+			}
+		}
+	}
+
+	for i := range resp.Results {
+		resp.Results[i].Index = i
+		if status := resp.Results[i].Status; status == batchStatusCreated || status == batchStatusDeleted {
+			resp.Accepted++
+		} else {
+			resp.Rejected++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// batchEntry validates item and converts it to a store entry. The returned
+// result is the item's outcome once the entry is stored, or invalid.
+func batchEntry(item batchItem) (collector.StatusEntry, batchResult) {
+	if item.Deleted {
+		if item.Cluster == "" || item.ClaimRef == "" {
+			return collector.StatusEntry{}, batchResult{Status: batchStatusInvalid, Error: "cluster and claimRef are required"}
+		}
+		entry := collector.StatusEntry{Cluster: item.Cluster, Kind: item.Kind, ClaimRef: item.ClaimRef, Deleted: true}
+		return entry, batchResult{Status: batchStatusDeleted}
+	}
+
+	if item.Cluster == "" || item.ClaimRef == "" || item.StatusMessage == "" {
+		return collector.StatusEntry{}, batchResult{Status: batchStatusInvalid, Error: "cluster, claimRef, and statusMessage are required"}
+	}
+	entry := collector.StatusEntry{
+		Cluster:       item.Cluster,
+		ClaimRef:      item.ClaimRef,
+		Kind:          item.Kind,
+		StatusMessage: item.StatusMessage,
+		Conditions:    item.Conditions,
+	}
+	return entry, batchResult{Status: batchStatusCreated}
+}
+
+// putBatch stores entries in a span of its own and records that span as the
+// entries' trace parent.
+func (s *Server) putBatch(ctx context.Context, entries []collector.StatusEntry) error {
+	ctx, span := tracing.Tracer().Start(ctx, "StatusStore.PutBatch", trace.WithAttributes(
+		attribute.Int("batch.size", len(entries)),
+	))
+	traceParent := tracing.TraceParent(ctx)
+	for i := range entries {
+		entries[i].TraceParent = traceParent
+	}
+	err := s.store.PutBatch(entries)
+	tracing.End(span, err)
+	return err
+}
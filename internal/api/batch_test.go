@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostStatusBatch(t *testing.T) {
+	srv := newTestServer()
+	srv.store.Put("cluster-a", "ns/gone", "ready")
+
+	body := `[
+		{"cluster":"cluster-a","claimRef":"ns/one","statusMessage":"ready"},
+		{"cluster":"cluster-a","claimRef":"ns/two"},
+		{"cluster":"cluster-a","claimRef":"ns/gone","deleted":true},
+		{"cluster":"cluster-b","claimRef":"ns/three","statusMessage":"pending","kind":"Bucket"}
+	]`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status:batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp batchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Accepted != 3 || resp.Rejected != 1 {
+		t.Fatalf("expected 3 accepted and 1 rejected, got %d/%d", resp.Accepted, resp.Rejected)
+	}
+	want := []string{batchStatusCreated, batchStatusInvalid, batchStatusDeleted, batchStatusCreated}
+	for i, r := range resp.Results {
+		if r.Index != i || r.Status != want[i] {
+			t.Errorf("result %d: expected %q, got %+v", i, want[i], r)
+		}
+	}
+
+	if _, ok, _ := srv.store.Get("cluster-a", "", "ns/two"); ok {
+		t.Error("expected invalid item not to be stored")
+	}
+	if e, _, _ := srv.store.Get("cluster-a", "", "ns/gone"); !e.Deleted {
+		t.Error("expected deleted item to be stored as tombstone")
+	}
+	if e, _, _ := srv.store.Get("cluster-b", "Bucket", "ns/three"); e.Kind != "Bucket" {
+		t.Errorf("expected kind 'Bucket', got %q", e.Kind)
+	}
+}
+
+func TestPostStatusBatch_Invalid(t *testing.T) {
+	srv := newTestServer()
+
+	cases := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"invalid JSON", "{invalid", http.StatusBadRequest},
+		{"not an array", `{"cluster":"a"}`, http.StatusBadRequest},
+		{"empty", `[]`, http.StatusBadRequest},
+		{"too large", "[" + strings.Repeat(`{"cluster":"a","claimRef":"c","statusMessage":"ok"},`, maxBatchSize) + `{"cluster":"a","claimRef":"c","statusMessage":"ok"}]`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/status:batch", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != tc.code {
+				t.Fatalf("expected %d, got %d", tc.code, rec.Code)
+			}
+		})
+	}
+}
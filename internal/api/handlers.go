@@ -1,52 +1,176 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
+	"github.com/stuttgart-things/machinery-status-collector/internal/registry"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type statusRequest struct {
-	Cluster       string `json:"cluster"`
-	ClaimRef      string `json:"claimRef"`
-	StatusMessage string `json:"statusMessage"`
+	Cluster       string               `json:"cluster"`
+	ClaimRef      string               `json:"claimRef"`
+	Kind          string               `json:"kind,omitempty"`
+	StatusMessage string               `json:"statusMessage"`
+	Conditions    []registry.Condition `json:"conditions,omitempty"`
 }
 
 type statusResponse struct {
-	Cluster       string `json:"cluster"`
-	ClaimRef      string `json:"claimRef"`
-	StatusMessage string `json:"statusMessage"`
-	ReceivedAt    string `json:"receivedAt"`
+	Cluster       string               `json:"cluster"`
+	ClaimRef      string               `json:"claimRef"`
+	Kind          string               `json:"kind,omitempty"`
+	StatusMessage string               `json:"statusMessage"`
+	Conditions    []registry.Condition `json:"conditions,omitempty"`
+	Ready         bool                 `json:"ready"`
+	Synced        bool                 `json:"synced"`
+	ReceivedAt    string               `json:"receivedAt"`
+	Deleted       bool                 `json:"deleted,omitempty"`
+}
+
+func newStatusResponse(e collector.StatusEntry) statusResponse {
+	return statusResponse{
+		Cluster:       e.Cluster,
+		ClaimRef:      e.ClaimRef,
+		Kind:          e.Kind,
+		StatusMessage: e.StatusMessage,
+		Conditions:    e.Conditions,
+		Ready:         e.Ready,
+		Synced:        e.Synced,
+		ReceivedAt:    e.ReceivedAt.Format(time.RFC3339),
+		Deleted:       e.Deleted,
+	}
+}
+
+// maxRequestBytes limits the body of write requests. It leaves ample room for
+// a full batch of maxBatchSize claims with their conditions.
+const maxRequestBytes = 8 << 20
+
+// decodeBody decodes the JSON body of r into v, reading at most
+// maxRequestBytes. On failure it writes the error response and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+		return false
+	case err != nil:
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return false
+	}
+	return true
 }
 
 func (s *Server) handlePostStatus(w http.ResponseWriter, r *http.Request) {
 	var req statusRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+	if !decodeBody(w, r, &req) {
 		return
 	}
 	if req.Cluster == "" || req.ClaimRef == "" || req.StatusMessage == "" {
 		http.Error(w, `{"error":"cluster, claimRef, and statusMessage are required"}`, http.StatusBadRequest)
 		return
 	}
+	if !clusterAllowed(r, req.Cluster) {
+		http.Error(w, `{"error":"not permitted to report for this cluster"}`, http.StatusForbidden)
+		return
+	}
 
-	s.store.Put(req.Cluster, req.ClaimRef, req.StatusMessage)
+	entry := collector.StatusEntry{
+		Cluster:       req.Cluster,
+		ClaimRef:      req.ClaimRef,
+		Kind:          req.Kind,
+		StatusMessage: req.StatusMessage,
+		Conditions:    req.Conditions,
+	}
+	if err := s.putEntry(r.Context(), entry); err != nil {
+		slog.Error("store status", "error", err)
+		if errors.Is(err, collector.ErrStoreFull) {
+			http.Error(w, `{"error":"status store is full"}`, http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, `{"error":"failed to store status"}`, http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{"status": "created"})
 }
 
+func (s *Server) handleDeleteStatus(w http.ResponseWriter, r *http.Request) {
+	cluster := r.PathValue("cluster")
+	claimRef := r.PathValue("claimRef")
+	if cluster == "" || claimRef == "" {
+		http.Error(w, `{"error":"cluster and claimRef are required"}`, http.StatusBadRequest)
+		return
+	}
+	if !clusterAllowed(r, cluster) {
+		http.Error(w, `{"error":"not permitted to report for this cluster"}`, http.StatusForbidden)
+		return
+	}
+
+	// Claims of different kinds may share a namespace and name; the kind
+	// query parameter tells them apart.
+	entry := collector.StatusEntry{Cluster: cluster, Kind: r.URL.Query().Get("kind"), ClaimRef: claimRef}
+	if err := s.deleteEntry(r.Context(), entry); err != nil {
+		slog.Error("store deletion", "error", err)
+		if errors.Is(err, collector.ErrStoreFull) {
+			http.Error(w, `{"error":"status store is full"}`, http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, `{"error":"failed to store deletion"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// putEntry stores entry in a span of its own and records that span as the
+// entry's trace parent.
+func (s *Server) putEntry(ctx context.Context, entry collector.StatusEntry) error {
+	ctx, span := tracing.Tracer().Start(ctx, "StatusStore.PutEntry", trace.WithAttributes(
+		attribute.String("cluster", entry.Cluster),
+		attribute.String("claim.kind", entry.Kind),
+		attribute.String("claim.ref", entry.ClaimRef),
+	))
+	entry.TraceParent = tracing.TraceParent(ctx)
+	err := s.store.PutEntry(entry)
+	tracing.End(span, err)
+	return err
+}
+
+// deleteEntry is the tombstone counterpart of putEntry.
+func (s *Server) deleteEntry(ctx context.Context, entry collector.StatusEntry) error {
+	ctx, span := tracing.Tracer().Start(ctx, "StatusStore.DeleteEntry", trace.WithAttributes(
+		attribute.String("cluster", entry.Cluster),
+		attribute.String("claim.kind", entry.Kind),
+		attribute.String("claim.ref", entry.ClaimRef),
+	))
+	entry.TraceParent = tracing.TraceParent(ctx)
+	err := s.store.DeleteEntry(entry)
+	tracing.End(span, err)
+	return err
+}
+
 func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
-	entries := s.store.GetAll()
+	entries, err := s.store.GetAll()
+	if err != nil {
+		slog.Error("read store", "error", err)
+		http.Error(w, `{"error":"failed to read status"}`, http.StatusInternalServerError)
+		return
+	}
 	resp := make([]statusResponse, 0, len(entries))
 	for _, e := range entries {
-		resp = append(resp, statusResponse{
-			Cluster:       e.Cluster,
-			ClaimRef:      e.ClaimRef,
-			StatusMessage: e.StatusMessage,
-			ReceivedAt:    e.ReceivedAt.Format(time.RFC3339),
-		})
+		resp = append(resp, newStatusResponse(e))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -55,16 +179,16 @@ func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleGetStatusByCluster(w http.ResponseWriter, r *http.Request) {
 	cluster := r.PathValue("cluster")
-	entries := s.store.GetAll()
+	entries, err := s.store.GetAll()
+	if err != nil {
+		slog.Error("read store", "error", err)
+		http.Error(w, `{"error":"failed to read status"}`, http.StatusInternalServerError)
+		return
+	}
 	resp := make([]statusResponse, 0)
 	for _, e := range entries {
 		if e.Cluster == cluster {
-			resp = append(resp, statusResponse{
-				Cluster:       e.Cluster,
-				ClaimRef:      e.ClaimRef,
-				StatusMessage: e.StatusMessage,
-				ReceivedAt:    e.ReceivedAt.Format(time.RFC3339),
-			})
+			resp = append(resp, newStatusResponse(e))
 		}
 	}
 
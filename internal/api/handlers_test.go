@@ -1,13 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
+	"github.com/stuttgart-things/machinery-status-collector/internal/health"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 func newTestServer() *Server {
@@ -28,7 +33,10 @@ func TestPostStatus_Valid(t *testing.T) {
 		t.Fatalf("expected 201, got %d", rec.Code)
 	}
 
-	entry, ok := srv.store.Get("cluster-a", "my/claim")
+	entry, ok, err := srv.store.Get("cluster-a", "", "my/claim")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if !ok {
 		t.Fatal("expected entry in store")
 	}
@@ -37,6 +45,70 @@ func TestPostStatus_Valid(t *testing.T) {
 	}
 }
 
+func TestPostStatus_StoresTraceParent(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	srv := newTestServer()
+
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	body := `{"cluster":"cluster-a","claimRef":"my/claim","statusMessage":"ready"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(body))
+	req.Header.Set("traceparent", traceParent)
+	rec := httptest.NewRecorder()
+
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	entry, _, err := srv.store.Get("cluster-a", "", "my/claim")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(entry.TraceParent, "4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("expected entry in trace 4bf92f3577b34da6a3ce929d0e0e4736, got %q", entry.TraceParent)
+	}
+}
+
+func TestPostStatus_Conditions(t *testing.T) {
+	srv := newTestServer()
+
+	body := `{"cluster":"cluster-a","claimRef":"my/claim","kind":"PostgreSQL","statusMessage":"waiting",` +
+		`"conditions":[{"type":"Synced","status":"True","reason":"ReconcileSuccess"},` +
+		`{"type":"Ready","status":"False","reason":"Creating","message":"waiting","lastTransitionTime":"2026-01-01T00:00:00Z"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/status/cluster-a", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	var resp []statusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(resp))
+	}
+	got := resp[0]
+	if got.Ready || !got.Synced {
+		t.Errorf("expected ready=false synced=true, got ready=%v synced=%v", got.Ready, got.Synced)
+	}
+	if len(got.Conditions) != 2 || got.Conditions[1].LastTransitionTime != "2026-01-01T00:00:00Z" {
+		t.Errorf("unexpected conditions: %+v", got.Conditions)
+	}
+	if got.Kind != "PostgreSQL" {
+		t.Errorf("expected kind 'PostgreSQL', got %q", got.Kind)
+	}
+	if got.StatusMessage != "waiting" {
+		t.Errorf("expected statusMessage 'waiting', got %q", got.StatusMessage)
+	}
+}
+
 func TestPostStatus_InvalidJSON(t *testing.T) {
 	srv := newTestServer()
 
@@ -73,6 +145,39 @@ func TestPostStatus_MissingFields(t *testing.T) {
 	}
 }
 
+// fullBackend rejects every write like a backend that reached its capacity.
+type fullBackend struct {
+	collector.Backend
+}
+
+func (fullBackend) Put(collector.StatusEntry) error        { return collector.ErrStoreFull }
+func (fullBackend) PutBatch([]collector.StatusEntry) error { return collector.ErrStoreFull }
+
+func TestPostStatus_StoreFull(t *testing.T) {
+	store := collector.NewStatusStoreWithBackend(fullBackend{collector.NewMemoryBackend()})
+	srv := NewServer(store, "v0.1.0-test", "abc1234")
+
+	body := `{"cluster":"cluster-a","claimRef":"my/claim","statusMessage":"ready"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "status store is full") {
+		t.Errorf("expected a store full error, got %q", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/status/cluster-a/my/claim", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 for a deletion, got %d", rec.Code)
+	}
+}
+
 func TestGetStatus(t *testing.T) {
 	srv := newTestServer()
 	srv.store.Put("cluster-a", "claim1", "ready")
@@ -123,6 +228,59 @@ func TestGetStatusByCluster(t *testing.T) {
 	}
 }
 
+func TestDeleteStatus(t *testing.T) {
+	srv := newTestServer()
+	srv.store.Put("cluster-a", "my/claim", "ready")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/status/cluster-a/my/claim", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	entry, ok, err := srv.store.Get("cluster-a", "", "my/claim")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok || !entry.Deleted {
+		t.Fatalf("expected tombstone in store, got %+v", entry)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/status/cluster-a", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	var resp []statusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 1 || !resp[0].Deleted {
+		t.Fatalf("expected one deleted entry, got %+v", resp)
+	}
+}
+
+func TestDeleteStatus_Kind(t *testing.T) {
+	srv := newTestServer()
+	srv.store.PutEntry(collector.StatusEntry{Cluster: "cluster-a", Kind: "PostgreSQL", ClaimRef: "my/claim", StatusMessage: "ready"})
+	srv.store.PutEntry(collector.StatusEntry{Cluster: "cluster-a", Kind: "Bucket", ClaimRef: "my/claim", StatusMessage: "ready"})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/status/cluster-a/my/claim?kind=Bucket", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if e, _, _ := srv.store.Get("cluster-a", "Bucket", "my/claim"); !e.Deleted {
+		t.Fatalf("expected tombstone for the Bucket, got %+v", e)
+	}
+	if e, _, _ := srv.store.Get("cluster-a", "PostgreSQL", "my/claim"); e.Deleted {
+		t.Fatal("expected the claim of another kind to stay untouched")
+	}
+}
+
 func TestHealthz(t *testing.T) {
 	srv := newTestServer()
 
@@ -143,6 +301,35 @@ func TestHealthz(t *testing.T) {
 	}
 }
 
+func TestReadyzAndLivez(t *testing.T) {
+	ready := &health.Checks{}
+	ready.Add("store", func(context.Context) error { return errors.New("store unavailable") })
+	srv := NewServer(collector.NewStatusStore(), "v0.1.0-test", "abc1234", WithReadinessChecks(ready))
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 from readyz, got %d", rec.Code)
+	}
+	var report health.Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Checks["store"].Error != "store unavailable" {
+		t.Fatalf("expected store check failure in report, got %+v", report)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/livez", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 from livez without checks, got %d", rec.Code)
+	}
+}
+
 func TestVersion(t *testing.T) {
 	srv := newTestServer()
 
@@ -194,3 +381,22 @@ func TestRecoveryMiddleware(t *testing.T) {
 		t.Fatalf("expected 500, got %d", rec.Code)
 	}
 }
+
+func TestMetrics(t *testing.T) {
+	srv := newTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status/cluster-a", nil)
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	want := `machinery_status_collector_http_requests_total{code="200",method="GET",route="GET /api/v1/status/{cluster}"}`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("expected metrics to contain %s", want)
+	}
+}
@@ -5,7 +5,14 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // responseWriter wraps http.ResponseWriter to capture the status code.
@@ -24,12 +31,47 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		start := time.Now()
 		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(rw, r)
+		duration := time.Since(start)
 		slog.Info("request",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", rw.statusCode,
-			"duration", time.Since(start).String(),
+			"duration", duration.String(),
+		)
+		// The mux sets r.Pattern on the request it routes, which is this
+		// one as long as inner middlewares do not replace it.
+		metrics.ObserveHTTPRequest(r.Method, r.Pattern, rw.statusCode, duration)
+	})
+}
+
+// tracingMiddleware starts a server span for every request, continuing the
+// trace propagated by the caller. It replaces the request, so middlewares
+// that need the routed r.Pattern must run inside it.
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
 		)
+		defer span.End()
+
+		r = r.WithContext(ctx)
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			_, route, _ := strings.Cut(r.Pattern, " ")
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.statusCode))
+		if rw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.statusCode))
+		}
 	})
 }
 
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
+	"github.com/stuttgart-things/machinery-status-collector/internal/health"
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
 )
 
 // Server holds the HTTP handler and its dependencies.
@@ -11,33 +13,88 @@ type Server struct {
 	store   *collector.StatusStore
 	version string
 	commit  string
+	auth    Authenticator
+	signer  *signatureVerifier
+	ready   *health.Checks
+	live    *health.Checks
 	Handler http.Handler
 }
 
+// ServerOption configures optional Server behaviour.
+type ServerOption func(*Server)
+
+// WithAuthenticator requires write requests to be authenticated by auth and
+// restricts each caller to the cluster bound to its identity.
+func WithAuthenticator(auth Authenticator) ServerOption {
+	return func(s *Server) {
+		s.auth = auth
+	}
+}
+
+// WithReadinessChecks serves checks at /readyz. Without it, /readyz always
+// reports ok.
+func WithReadinessChecks(checks *health.Checks) ServerOption {
+	return func(s *Server) {
+		s.ready = checks
+	}
+}
+
+// WithLivenessChecks serves checks at /livez. Without it, /livez always
+// reports ok.
+func WithLivenessChecks(checks *health.Checks) ServerOption {
+	return func(s *Server) {
+		s.live = checks
+	}
+}
+
 // NewServer creates a Server with all routes and middleware registered.
-func NewServer(store *collector.StatusStore, version, commit string) *Server {
+func NewServer(store *collector.StatusStore, version, commit string, opts ...ServerOption) *Server {
 	s := &Server{
 		store:   store,
 		version: version,
 		commit:  commit,
+		ready:   &health.Checks{},
+		live:    &health.Checks{},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /api/v1/status", s.handlePostStatus)
+	mux.Handle("POST /api/v1/status", s.write(s.handlePostStatus))
+	mux.Handle("POST /api/v1/status:batch", s.write(s.handlePostStatusBatch))
 	mux.HandleFunc("GET /api/v1/status", s.handleGetStatus)
 	mux.HandleFunc("GET /api/v1/status/{cluster}", s.handleGetStatusByCluster)
+	mux.Handle("DELETE /api/v1/status/{cluster}/{claimRef...}", s.write(s.handleDeleteStatus))
 	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.Handle("GET /readyz", s.ready.Handler())
+	mux.Handle("GET /livez", s.live.Handler())
 	mux.HandleFunc("GET /version", s.handleVersion)
+	mux.Handle("GET /metrics", metrics.Handler())
 
-	s.Handler = wrapMiddleware(mux,
+	middlewares := []func(http.Handler) http.Handler{
 		recoveryMiddleware,
 		requestIDMiddleware,
+		tracingMiddleware,
 		loggingMiddleware,
-	)
+	}
+	if s.signer != nil {
+		middlewares = append(middlewares, signatureMiddleware(s.signer))
+	}
+	s.Handler = wrapMiddleware(mux, middlewares...)
 
 	return s
 }
 
+// write wraps a handler that modifies the store with authentication, if an
+// Authenticator is configured.
+func (s *Server) write(h http.HandlerFunc) http.Handler {
+	if s.auth == nil {
+		return h
+	}
+	return authMiddleware(s.auth)(h)
+}
+
 // Start begins listening on the given address.
 func (s *Server) Start(addr string) error {
 	return http.ListenAndServe(addr, s.Handler)
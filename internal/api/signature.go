@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/signing"
+)
+
+// defaultSignatureMaxAge is how far a signature timestamp may deviate from
+// the server clock, in either direction.
+const defaultSignatureMaxAge = 5 * time.Minute
+
+// WithRequestSigning requires write requests to carry a valid HMAC signature
+// made with secret. Requests whose timestamp is more than maxAge away from
+// the server clock, or whose nonce was already seen, are rejected. A zero
+// maxAge uses a default of five minutes.
+//
+// Seen nonces are kept in memory by each server. Behind several replicas a
+// captured request can therefore be replayed once per replica within maxAge.
+func WithRequestSigning(secret []byte, maxAge time.Duration) ServerOption {
+	return func(s *Server) {
+		if maxAge <= 0 {
+			maxAge = defaultSignatureMaxAge
+		}
+		s.signer = &signatureVerifier{
+			secret: secret,
+			maxAge: maxAge,
+			nonces: newNonceCache(),
+			now:    time.Now,
+		}
+	}
+}
+
+type signatureVerifier struct {
+	secret []byte
+	maxAge time.Duration
+	nonces *nonceCache
+	now    func() time.Time
+}
+
+// signatureMiddleware verifies the signature of requests that modify the
+// store. Read requests pass through unsigned.
+func signatureMiddleware(v *signatureVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			timestamp := r.Header.Get(signing.HeaderTimestamp)
+			nonce := r.Header.Get(signing.HeaderNonce)
+			signature := r.Header.Get(signing.HeaderSignature)
+			if timestamp == "" || nonce == "" || signature == "" {
+				http.Error(w, `{"error":"missing signature"}`, http.StatusUnauthorized)
+				return
+			}
+
+			sec, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				http.Error(w, `{"error":"invalid signature timestamp"}`, http.StatusUnauthorized)
+				return
+			}
+			now := v.now()
+			signedAt := time.Unix(sec, 0)
+			if signedAt.Before(now.Add(-v.maxAge)) || signedAt.After(now.Add(v.maxAge)) {
+				http.Error(w, `{"error":"stale request"}`, http.StatusUnauthorized)
+				return
+			}
+
+			// The body is read before the signature is checked, so it is
+			// bounded like in the handlers.
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, `{"error":"failed to read body"}`, http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// The query is signed along with the path, e.g. the kind of a
+			// deleted claim.
+			if !signing.Verify(v.secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body, signature) {
+				http.Error(w, `{"error":"invalid signature"}`, http.StatusUnauthorized)
+				return
+			}
+			// Only remember nonces of valid signatures, so unauthenticated
+			// clients cannot fill the cache.
+			if !v.nonces.add(nonce, signedAt.Add(v.maxAge), now) {
+				http.Error(w, `{"error":"replayed request"}`, http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// nonceCache remembers nonces until their signature has expired. Older
+// nonces need not be kept because their requests are rejected as stale.
+type nonceCache struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{expires: make(map[string]time.Time)}
+}
+
+// add records nonce until expiry and reports whether it was new.
+func (c *nonceCache) add(nonce string, expiry, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > time.Minute {
+		for n, exp := range c.expires {
+			if now.After(exp) {
+				delete(c.expires, n)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, ok := c.expires[nonce]; ok && !now.After(exp) {
+		return false
+	}
+	c.expires[nonce] = expiry
+	return true
+}
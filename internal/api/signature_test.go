@@ -0,0 +1,163 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/collector"
+	"github.com/stuttgart-things/machinery-status-collector/internal/signing"
+)
+
+var testSigningSecret = []byte("s3cr3t")
+
+func newSigningTestServer(now time.Time) *Server {
+	srv := NewServer(collector.NewStatusStore(), "v0.1.0-test", "abc1234", WithRequestSigning(testSigningSecret, time.Minute))
+	srv.signer.now = func() time.Time { return now }
+	return srv
+}
+
+func signedStatusRequest(t *testing.T, secret []byte, signedAt time.Time) *http.Request {
+	t.Helper()
+	body := `{"cluster":"cluster-01","claimRef":"default/my-db","statusMessage":"ready"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(body))
+	if err := signing.SignRequest(req, secret, signedAt); err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	return req
+}
+
+func TestSignature_PostStatus(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	cases := []struct {
+		name string
+		req  func(t *testing.T) *http.Request
+		code int
+	}{
+		{"valid", func(t *testing.T) *http.Request {
+			return signedStatusRequest(t, testSigningSecret, now)
+		}, http.StatusCreated},
+		{"unsigned", func(t *testing.T) *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(`{}`))
+		}, http.StatusUnauthorized},
+		{"wrong secret", func(t *testing.T) *http.Request {
+			return signedStatusRequest(t, []byte("other"), now)
+		}, http.StatusUnauthorized},
+		{"stale", func(t *testing.T) *http.Request {
+			return signedStatusRequest(t, testSigningSecret, now.Add(-2*time.Minute))
+		}, http.StatusUnauthorized},
+		{"from the future", func(t *testing.T) *http.Request {
+			return signedStatusRequest(t, testSigningSecret, now.Add(2*time.Minute))
+		}, http.StatusUnauthorized},
+		{"tampered body", func(t *testing.T) *http.Request {
+			signed := signedStatusRequest(t, testSigningSecret, now)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/status",
+				strings.NewReader(`{"cluster":"cluster-02","claimRef":"default/my-db","statusMessage":"ready"}`))
+			req.Header = signed.Header
+			return req
+		}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newSigningTestServer(now)
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, tc.req(t))
+			if rec.Code != tc.code {
+				t.Fatalf("expected %d, got %d: %s", tc.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSignature_Replay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	srv := newSigningTestServer(now)
+
+	req := signedStatusRequest(t, testSigningSecret, now)
+	replay := httptest.NewRequest(http.MethodPost, "/api/v1/status",
+		strings.NewReader(`{"cluster":"cluster-01","claimRef":"default/my-db","statusMessage":"ready"}`))
+	replay.Header = req.Header.Clone()
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, replay)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected replay to be rejected with 401, got %d", rec.Code)
+	}
+}
+
+func TestSignature_DeleteQuery(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	srv := newSigningTestServer(now)
+
+	signed := httptest.NewRequest(http.MethodDelete, "/api/v1/status/cluster-01/default/my-db?kind=Bucket", nil)
+	if err := signing.SignRequest(signed, testSigningSecret, now); err != nil {
+		t.Fatalf("sign request: %v", err)
+	}
+	tampered := httptest.NewRequest(http.MethodDelete, "/api/v1/status/cluster-01/default/my-db?kind=PostgreSQL", nil)
+	tampered.Header = signed.Header.Clone()
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, tampered)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a changed query to be rejected with 401, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, signed)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSignature_BodyTooLarge(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	srv := newSigningTestServer(now)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(strings.Repeat(" ", maxRequestBytes+1)))
+	req.Header.Set(signing.HeaderTimestamp, "1700000000")
+	req.Header.Set(signing.HeaderNonce, "n1")
+	req.Header.Set(signing.HeaderSignature, "invalid")
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rec.Code)
+	}
+}
+
+func TestSignature_ReadsStayOpen(t *testing.T) {
+	srv := newSigningTestServer(time.Now())
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestNonceCache_Expiry(t *testing.T) {
+	c := newNonceCache()
+	now := time.Unix(1700000000, 0)
+
+	if !c.add("n1", now.Add(time.Minute), now) {
+		t.Fatal("expected first nonce to be new")
+	}
+	if c.add("n1", now.Add(time.Minute), now.Add(30*time.Second)) {
+		t.Fatal("expected repeated nonce to be rejected")
+	}
+
+	later := now.Add(2 * time.Minute)
+	c.add("n2", later.Add(time.Minute), later)
+	if _, ok := c.expires["n1"]; ok {
+		t.Error("expected expired nonce to be swept")
+	}
+}
@@ -0,0 +1,276 @@
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltEntriesBucket = []byte("entries")
+	boltMetaBucket    = []byte("meta")
+	boltGenerationKey = []byte("generation")
+	boltFlushedKey    = []byte("flushed")
+)
+
+// BoltBackend is a Backend that persists entries, the generation counter and
+// the flushed watermark in an embedded bbolt database file, so unflushed
+// updates survive restarts.
+type BoltBackend struct {
+	db *bolt.DB
+	// path is set for a shared backend, which opens the database for every
+	// operation instead of holding it open.
+	path string
+}
+
+// NewBoltBackend opens (or creates) the bbolt database at path. The database
+// is locked while the backend is open.
+func NewBoltBackend(path string) (*BoltBackend, error) {
+	db, err := openBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BoltBackend{db: db}, nil
+}
+
+// NewSharedBoltBackend uses the bbolt database at path, creating it if
+// needed, but only locks it for the duration of each operation. Several
+// processes on one host can share it, e.g. collectors electing a leader
+// through a lock file.
+func NewSharedBoltBackend(path string) (*BoltBackend, error) {
+	db, err := openBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, fmt.Errorf("close bolt db: %w", err)
+	}
+	return &BoltBackend{path: path}, nil
+}
+
+// openBolt opens the database at path and creates its buckets.
+func openBolt(path string) (*bolt.DB, error) {
+	db, err := openBoltFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltEntriesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create buckets: %w", err)
+	}
+	return db, nil
+}
+
+// openBoltFile opens the database at path, waiting for another process to
+// release it.
+func openBoltFile(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt db: %w", err)
+	}
+	return db, nil
+}
+
+// update runs fn in a read-write transaction.
+func (b *BoltBackend) update(fn func(*bolt.Tx) error) error {
+	if b.path == "" {
+		return b.db.Update(fn)
+	}
+	db, err := openBoltFile(b.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+// view runs fn in a read-only transaction.
+func (b *BoltBackend) view(fn func(*bolt.Tx) error) error {
+	if b.path == "" {
+		return b.db.View(fn)
+	}
+	db, err := openBoltFile(b.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// Put inserts or updates an entry and assigns it the next generation.
+func (b *BoltBackend) Put(entry StatusEntry) error {
+	return b.PutBatch([]StatusEntry{entry})
+}
+
+// PutBatch inserts or updates entries in a single transaction, assigning
+// them consecutive generations.
+func (b *BoltBackend) PutBatch(entries []StatusEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMetaBucket)
+		bucket := tx.Bucket(boltEntriesBucket)
+		generation := getUint64(meta, boltGenerationKey)
+		for _, entry := range entries {
+			generation++
+			entry.Generation = generation
+
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("marshal entry: %w", err)
+			}
+			key := []byte(storeKey(entry.Cluster, entry.Kind, entry.ClaimRef))
+			if err := bucket.Put(key, data); err != nil {
+				return fmt.Errorf("put entry: %w", err)
+			}
+		}
+		if err := putUint64(meta, boltGenerationKey, generation); err != nil {
+			return fmt.Errorf("put generation: %w", err)
+		}
+		return nil
+	})
+}
+
+// Get retrieves an entry by cluster, kind and claimRef.
+func (b *BoltBackend) Get(cluster, kind, claimRef string) (StatusEntry, bool, error) {
+	var (
+		entry StatusEntry
+		found bool
+	)
+	err := b.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltEntriesBucket).Get([]byte(storeKey(cluster, kind, claimRef)))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &entry)
+	})
+	if err != nil {
+		return StatusEntry{}, false, fmt.Errorf("get entry: %w", err)
+	}
+	return entry, found, nil
+}
+
+// GetAll returns a snapshot copy of all entries.
+func (b *BoltBackend) GetAll() ([]StatusEntry, error) {
+	var result []StatusEntry
+	err := b.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEntriesBucket)
+		result = make([]StatusEntry, 0, bucket.Stats().KeyN)
+		return bucket.ForEach(func(_, v []byte) error {
+			var e StatusEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			result = append(result, e)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get all entries: %w", err)
+	}
+	return result, nil
+}
+
+// Generation returns the generation assigned by the most recent Put.
+func (b *BoltBackend) Generation() (uint64, error) {
+	var generation uint64
+	err := b.view(func(tx *bolt.Tx) error {
+		generation = getUint64(tx.Bucket(boltMetaBucket), boltGenerationKey)
+		return nil
+	})
+	return generation, err
+}
+
+// Flushed returns the flushed watermark.
+func (b *BoltBackend) Flushed() (uint64, error) {
+	var flushed uint64
+	err := b.view(func(tx *bolt.Tx) error {
+		flushed = getUint64(tx.Bucket(boltMetaBucket), boltFlushedKey)
+		return nil
+	})
+	return flushed, err
+}
+
+// IsDirty reports whether any entry is newer than the flushed watermark.
+func (b *BoltBackend) IsDirty() (bool, error) {
+	var dirty bool
+	err := b.view(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMetaBucket)
+		dirty = getUint64(meta, boltGenerationKey) > getUint64(meta, boltFlushedKey)
+		return nil
+	})
+	return dirty, err
+}
+
+// MarkFlushed advances the flushed watermark to generation. The watermark
+// never moves backwards.
+func (b *BoltBackend) MarkFlushed(generation uint64) error {
+	return b.update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(boltMetaBucket)
+		if generation <= getUint64(meta, boltFlushedKey) {
+			return nil
+		}
+		return putUint64(meta, boltFlushedKey, generation)
+	})
+}
+
+// Purge removes entries whose stored generation is unchanged in a single
+// transaction.
+func (b *BoltBackend) Purge(entries []StatusEntry) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEntriesBucket)
+		for _, entry := range entries {
+			key := []byte(storeKey(entry.Cluster, entry.Kind, entry.ClaimRef))
+			data := bucket.Get(key)
+			if data == nil {
+				continue
+			}
+			var e StatusEntry
+			if err := json.Unmarshal(data, &e); err != nil {
+				return fmt.Errorf("unmarshal entry: %w", err)
+			}
+			if e.Generation != entry.Generation {
+				continue
+			}
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("purge entry: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the underlying database file.
+func (b *BoltBackend) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Close()
+}
+
+func getUint64(bucket *bolt.Bucket, key []byte) uint64 {
+	v := bucket.Get(key)
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+func putUint64(bucket *bolt.Bucket, key []byte, v uint64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return bucket.Put(key, buf)
+}
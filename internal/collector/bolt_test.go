@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltBackend_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.db")
+
+	b, err := NewBoltBackend(path)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	s := NewStatusStoreWithBackend(b)
+	if err := s.Put("cluster-01", "postgresqls.2.2.2/my-db", "Ready"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	b, err = NewBoltBackend(path)
+	if err != nil {
+		t.Fatalf("reopen backend: %v", err)
+	}
+	s = NewStatusStoreWithBackend(b)
+	defer s.Close()
+
+	if !isDirty(t, s) {
+		t.Fatal("expected dirty state to survive reopen")
+	}
+
+	e, ok, err := s.Get("cluster-01", "", "postgresqls.2.2.2/my-db")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected entry to survive reopen")
+	}
+	if e.StatusMessage != "Ready" {
+		t.Errorf("expected StatusMessage 'Ready', got %q", e.StatusMessage)
+	}
+	if e.ReceivedAt.IsZero() {
+		t.Error("expected ReceivedAt to be set")
+	}
+
+	// The generation counter must continue where it left off.
+	s.Put("cluster-01", "postgresqls.2.2.2/my-db", "Degraded")
+	e, _, _ = s.Get("cluster-01", "", "postgresqls.2.2.2/my-db")
+	if e.Generation != 2 {
+		t.Errorf("expected generation 2 after reopen, got %d", e.Generation)
+	}
+}
+
+func TestBoltBackend_MarkFlushed(t *testing.T) {
+	b, err := NewBoltBackend(filepath.Join(t.TempDir(), "status.db"))
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	s := NewStatusStoreWithBackend(b)
+	defer s.Close()
+
+	if isDirty(t, s) {
+		t.Fatal("new store should not be dirty")
+	}
+
+	s.Put("cluster-01", "claim/a", "Ready")
+	s.Put("cluster-02", "claim/b", "Pending")
+	if !isDirty(t, s) {
+		t.Fatal("store should be dirty after Put")
+	}
+
+	gen, err := s.Generation()
+	if err != nil {
+		t.Fatalf("generation: %v", err)
+	}
+	if gen != 2 {
+		t.Fatalf("expected generation 2, got %d", gen)
+	}
+	if err := s.MarkFlushed(gen); err != nil {
+		t.Fatalf("mark flushed: %v", err)
+	}
+	if isDirty(t, s) {
+		t.Fatal("store should not be dirty after MarkFlushed")
+	}
+
+	entries, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+}
+
+func TestBoltBackend_PutBatch(t *testing.T) {
+	b, err := NewBoltBackend(filepath.Join(t.TempDir(), "status.db"))
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	defer b.Close()
+
+	if err := b.PutBatch([]StatusEntry{
+		{Cluster: "cluster-01", ClaimRef: "claim/a"},
+		{Cluster: "cluster-01", ClaimRef: "claim/b"},
+	}); err != nil {
+		t.Fatalf("put batch: %v", err)
+	}
+
+	if gen, _ := b.Generation(); gen != 2 {
+		t.Fatalf("expected generation 2, got %d", gen)
+	}
+	e, ok, err := b.Get("cluster-01", "", "claim/b")
+	if err != nil || !ok || e.Generation != 2 {
+		t.Fatalf("expected claim/b at generation 2, got %+v (found=%v, err=%v)", e, ok, err)
+	}
+}
+
+func TestBoltBackend_Shared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.db")
+
+	// Two backends on one file stand in for collectors on the same host.
+	leader, err := NewSharedBoltBackend(path)
+	if err != nil {
+		t.Fatalf("open backend: %v", err)
+	}
+	defer leader.Close()
+	follower, err := NewSharedBoltBackend(path)
+	if err != nil {
+		t.Fatalf("open second backend: %v", err)
+	}
+	defer follower.Close()
+
+	if err := follower.Put(StatusEntry{Cluster: "cluster-01", ClaimRef: "claim/a"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	e, ok, err := leader.Get("cluster-01", "", "claim/a")
+	if err != nil || !ok || e.Generation != 1 {
+		t.Fatalf("expected the follower's entry at generation 1, got %+v (found=%v, err=%v)", e, ok, err)
+	}
+
+	if err := leader.MarkFlushed(1); err != nil {
+		t.Fatalf("mark flushed: %v", err)
+	}
+	if dirty, _ := follower.IsDirty(); dirty {
+		t.Error("expected the watermark to be shared")
+	}
+}
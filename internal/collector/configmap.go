@@ -0,0 +1,248 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	configMapEntriesKey    = "entries.json"
+	configMapGenerationKey = "generation"
+	configMapFlushedKey    = "flushed"
+
+	configMapTimeout = 10 * time.Second
+
+	// configMapMaxEntriesBytes keeps the encoded entries below the 1 MiB
+	// limit of a ConfigMap, leaving room for the metadata.
+	configMapMaxEntriesBytes = 960 << 10
+)
+
+// ConfigMapBackend is a Backend that keeps all entries, the generation
+// counter and the flushed watermark in a single Kubernetes ConfigMap, so
+// several collector replicas can share one store. Writes use optimistic
+// concurrency on the ConfigMap's resourceVersion and are retried on conflict,
+// which keeps Put, PutBatch and MarkFlushed atomic across replicas.
+//
+// ConfigMaps are limited to 1 MiB, which bounds the number of entries to a
+// few thousand. Writes that would grow the entries beyond
+// configMapMaxEntriesBytes fail with ErrStoreFull; writes that shrink them,
+// such as purging tombstones, always succeed.
+type ConfigMapBackend struct {
+	client corev1client.ConfigMapInterface
+	name   string
+}
+
+// configMapState is the decoded content of the ConfigMap.
+type configMapState struct {
+	entries    map[string]StatusEntry
+	generation uint64
+	flushed    uint64
+}
+
+// NewConfigMapBackend uses the ConfigMap name in namespace, creating it if it
+// does not exist.
+func NewConfigMapBackend(client kubernetes.Interface, namespace, name string) (*ConfigMapBackend, error) {
+	b := &ConfigMapBackend{client: client.CoreV1().ConfigMaps(namespace), name: name}
+
+	ctx, cancel := context.WithTimeout(context.Background(), configMapTimeout)
+	defer cancel()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"app.kubernetes.io/managed-by": "machinery-status-collector"},
+		},
+	}
+	if err := encodeConfigMap(cm, configMapState{entries: map[string]StatusEntry{}}); err != nil {
+		return nil, err
+	}
+	_, err := b.client.Create(ctx, cm, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("create configmap %s/%s: %w", namespace, name, err)
+	}
+	return b, nil
+}
+
+// Put inserts or updates an entry and assigns it the next generation.
+func (b *ConfigMapBackend) Put(entry StatusEntry) error {
+	return b.PutBatch([]StatusEntry{entry})
+}
+
+// PutBatch inserts or updates entries in a single ConfigMap update, assigning
+// them consecutive generations.
+func (b *ConfigMapBackend) PutBatch(entries []StatusEntry) error {
+	return b.update(func(state *configMapState) bool {
+		for _, entry := range entries {
+			state.generation++
+			entry.Generation = state.generation
+			state.entries[storeKey(entry.Cluster, entry.Kind, entry.ClaimRef)] = entry
+		}
+		return len(entries) > 0
+	})
+}
+
+// Get retrieves an entry by cluster, kind and claimRef.
+func (b *ConfigMapBackend) Get(cluster, kind, claimRef string) (StatusEntry, bool, error) {
+	_, state, err := b.load()
+	if err != nil {
+		return StatusEntry{}, false, err
+	}
+	e, ok := state.entries[storeKey(cluster, kind, claimRef)]
+	return e, ok, nil
+}
+
+// GetAll returns a snapshot copy of all entries.
+func (b *ConfigMapBackend) GetAll() ([]StatusEntry, error) {
+	_, state, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]StatusEntry, 0, len(state.entries))
+	for _, e := range state.entries {
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// Generation returns the generation assigned by the most recent Put.
+func (b *ConfigMapBackend) Generation() (uint64, error) {
+	_, state, err := b.load()
+	return state.generation, err
+}
+
+// Flushed returns the flushed watermark.
+func (b *ConfigMapBackend) Flushed() (uint64, error) {
+	_, state, err := b.load()
+	return state.flushed, err
+}
+
+// IsDirty reports whether any entry is newer than the flushed watermark.
+func (b *ConfigMapBackend) IsDirty() (bool, error) {
+	_, state, err := b.load()
+	return state.generation > state.flushed, err
+}
+
+// MarkFlushed advances the flushed watermark to generation. The watermark
+// never moves backwards.
+func (b *ConfigMapBackend) MarkFlushed(generation uint64) error {
+	return b.update(func(state *configMapState) bool {
+		if generation <= state.flushed {
+			return false
+		}
+		state.flushed = generation
+		return true
+	})
+}
+
+// Purge removes entries whose stored generation is unchanged in a single
+// ConfigMap update.
+func (b *ConfigMapBackend) Purge(entries []StatusEntry) error {
+	return b.update(func(state *configMapState) bool {
+		changed := false
+		for _, entry := range entries {
+			key := storeKey(entry.Cluster, entry.Kind, entry.ClaimRef)
+			if e, ok := state.entries[key]; ok && e.Generation == entry.Generation {
+				delete(state.entries, key)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// Close is a no-op for the ConfigMap backend.
+func (b *ConfigMapBackend) Close() error {
+	return nil
+}
+
+func (b *ConfigMapBackend) load() (*corev1.ConfigMap, configMapState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), configMapTimeout)
+	defer cancel()
+	cm, err := b.client.Get(ctx, b.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, configMapState{}, fmt.Errorf("get configmap %s: %w", b.name, err)
+	}
+	state, err := decodeConfigMap(cm)
+	if err != nil {
+		return nil, configMapState{}, fmt.Errorf("decode configmap %s: %w", b.name, err)
+	}
+	return cm, state, nil
+}
+
+// update applies mutate to the current state and writes it back, retrying
+// with fresh state if another replica updated the ConfigMap in between.
+// mutate returns false if there is nothing to write.
+func (b *ConfigMapBackend) update(mutate func(*configMapState) bool) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		cm, state, err := b.load()
+		if err != nil {
+			return err
+		}
+		if !mutate(&state) {
+			return nil
+		}
+		size := len(cm.Data[configMapEntriesKey])
+		if err := encodeConfigMap(cm, state); err != nil {
+			return err
+		}
+		if n := len(cm.Data[configMapEntriesKey]); n > configMapMaxEntriesBytes && n > size {
+			metrics.ObserveStoreFull()
+			return fmt.Errorf("update configmap %s: %d bytes of entries exceed the limit of %d: %w", b.name, n, configMapMaxEntriesBytes, ErrStoreFull)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), configMapTimeout)
+		defer cancel()
+		if _, err := b.client.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			if apierrors.IsConflict(err) {
+				return err
+			}
+			return fmt.Errorf("update configmap %s: %w", b.name, err)
+		}
+		return nil
+	})
+}
+
+func decodeConfigMap(cm *corev1.ConfigMap) (configMapState, error) {
+	state := configMapState{entries: map[string]StatusEntry{}}
+	var err error
+	if v := cm.Data[configMapGenerationKey]; v != "" {
+		if state.generation, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return state, fmt.Errorf("parse generation: %w", err)
+		}
+	}
+	if v := cm.Data[configMapFlushedKey]; v != "" {
+		if state.flushed, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return state, fmt.Errorf("parse flushed watermark: %w", err)
+		}
+	}
+	if v := cm.Data[configMapEntriesKey]; v != "" {
+		if err := json.Unmarshal([]byte(v), &state.entries); err != nil {
+			return state, fmt.Errorf("unmarshal entries: %w", err)
+		}
+	}
+	return state, nil
+}
+
+func encodeConfigMap(cm *corev1.ConfigMap, state configMapState) error {
+	entries, err := json.Marshal(state.entries)
+	if err != nil {
+		return fmt.Errorf("marshal entries: %w", err)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[configMapEntriesKey] = string(entries)
+	cm.Data[configMapGenerationKey] = strconv.FormatUint(state.generation, 10)
+	cm.Data[configMapFlushedKey] = strconv.FormatUint(state.flushed, 10)
+	return nil
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapBackend_SharedAcrossReplicas(t *testing.T) {
+	client := fake.NewClientset()
+
+	b1, err := NewConfigMapBackend(client, "default", "status-store")
+	if err != nil {
+		t.Fatalf("create backend: %v", err)
+	}
+	b2, err := NewConfigMapBackend(client, "default", "status-store")
+	if err != nil {
+		t.Fatalf("create second backend on existing configmap: %v", err)
+	}
+	s1, s2 := NewStatusStoreWithBackend(b1), NewStatusStoreWithBackend(b2)
+
+	if isDirty(t, s1) {
+		t.Fatal("new store should not be dirty")
+	}
+	if err := s1.Put("cluster-01", "postgresqls.2.2.2/my-db", "Ready"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := s2.Put("cluster-02", "claim/b", "Pending"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	e, ok, err := s2.Get("cluster-01", "", "postgresqls.2.2.2/my-db")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !ok || e.StatusMessage != "Ready" {
+		t.Fatalf("expected entry written by the other replica, got %+v (found=%v)", e, ok)
+	}
+	if e.Generation != 1 {
+		t.Errorf("expected generation 1, got %d", e.Generation)
+	}
+
+	entries, watermark, err := s1.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if len(entries) != 2 || watermark != 2 {
+		t.Fatalf("expected 2 entries at generation 2, got %d at %d", len(entries), watermark)
+	}
+	if err := s1.MarkFlushed(watermark); err != nil {
+		t.Fatalf("mark flushed: %v", err)
+	}
+	if isDirty(t, s2) {
+		t.Fatal("store should not be dirty after MarkFlushed on another replica")
+	}
+
+	// The watermark never moves backwards.
+	if err := s2.MarkFlushed(1); err != nil {
+		t.Fatalf("mark flushed: %v", err)
+	}
+	if flushed, _ := s2.Flushed(); flushed != 2 {
+		t.Errorf("expected flushed watermark 2, got %d", flushed)
+	}
+}
+
+func TestConfigMapBackend_PutBatchSingleUpdate(t *testing.T) {
+	client := fake.NewClientset()
+	b, err := NewConfigMapBackend(client, "default", "status-store")
+	if err != nil {
+		t.Fatalf("create backend: %v", err)
+	}
+	client.ClearActions()
+
+	entries := []StatusEntry{
+		{Cluster: "cluster-01", ClaimRef: "claim/a"},
+		{Cluster: "cluster-01", ClaimRef: "claim/b"},
+		{Cluster: "cluster-02", ClaimRef: "claim/a"},
+	}
+	if err := b.PutBatch(entries); err != nil {
+		t.Fatalf("put batch: %v", err)
+	}
+
+	var updates int
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if updates != 1 {
+		t.Fatalf("expected one ConfigMap update for the batch, got %d", updates)
+	}
+	if gen, _ := b.Generation(); gen != 3 {
+		t.Fatalf("expected generation 3, got %d", gen)
+	}
+}
+
+func TestConfigMapBackend_Full(t *testing.T) {
+	b, err := NewConfigMapBackend(fake.NewClientset(), "default", "status-store")
+	if err != nil {
+		t.Fatalf("create backend: %v", err)
+	}
+
+	big := StatusEntry{Cluster: "cluster-01", ClaimRef: "claim/big", StatusMessage: strings.Repeat("x", 900<<10)}
+	if err := b.Put(big); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	err = b.Put(StatusEntry{Cluster: "cluster-01", ClaimRef: "claim/a", StatusMessage: strings.Repeat("x", 100<<10)})
+	if !errors.Is(err, ErrStoreFull) {
+		t.Fatalf("expected ErrStoreFull, got %v", err)
+	}
+	if gen, _ := b.Generation(); gen != 1 {
+		t.Fatalf("expected the rejected write to leave generation 1, got %d", gen)
+	}
+
+	// Writes that shrink the store are still accepted.
+	big.StatusMessage = "Ready"
+	if err := b.Put(big); err != nil {
+		t.Fatalf("expected a shrinking write to succeed, got %v", err)
+	}
+}
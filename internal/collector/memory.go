@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"sync"
+)
+
+// MemoryBackend is a thread-safe in-memory Backend. Its contents are lost when
+// the process exits.
+type MemoryBackend struct {
+	sync.RWMutex
+	entries    map[string]StatusEntry
+	generation uint64
+	flushed    uint64
+}
+
+// NewMemoryBackend creates an empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries: make(map[string]StatusEntry),
+	}
+}
+
+// Put inserts or updates an entry and assigns it the next generation.
+func (m *MemoryBackend) Put(entry StatusEntry) error {
+	return m.PutBatch([]StatusEntry{entry})
+}
+
+// PutBatch inserts or updates entries, assigning them consecutive
+// generations.
+func (m *MemoryBackend) PutBatch(entries []StatusEntry) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, entry := range entries {
+		m.generation++
+		entry.Generation = m.generation
+		m.entries[storeKey(entry.Cluster, entry.Kind, entry.ClaimRef)] = entry
+	}
+	return nil
+}
+
+// Get retrieves an entry by cluster, kind and claimRef.
+func (m *MemoryBackend) Get(cluster, kind, claimRef string) (StatusEntry, bool, error) {
+	m.RLock()
+	defer m.RUnlock()
+	e, ok := m.entries[storeKey(cluster, kind, claimRef)]
+	return e, ok, nil
+}
+
+// GetAll returns a snapshot copy of all entries.
+func (m *MemoryBackend) GetAll() ([]StatusEntry, error) {
+	m.RLock()
+	defer m.RUnlock()
+	result := make([]StatusEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// Generation returns the generation assigned by the most recent Put.
+func (m *MemoryBackend) Generation() (uint64, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.generation, nil
+}
+
+// Flushed returns the flushed watermark.
+func (m *MemoryBackend) Flushed() (uint64, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.flushed, nil
+}
+
+// IsDirty reports whether any entry is newer than the flushed watermark.
+func (m *MemoryBackend) IsDirty() (bool, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.generation > m.flushed, nil
+}
+
+// MarkFlushed advances the flushed watermark to generation. The watermark
+// never moves backwards.
+func (m *MemoryBackend) MarkFlushed(generation uint64) error {
+	m.Lock()
+	defer m.Unlock()
+	if generation > m.flushed {
+		m.flushed = generation
+	}
+	return nil
+}
+
+// Purge removes entries whose stored generation is unchanged.
+func (m *MemoryBackend) Purge(entries []StatusEntry) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, entry := range entries {
+		key := storeKey(entry.Cluster, entry.Kind, entry.ClaimRef)
+		if e, ok := m.entries[key]; ok && e.Generation == entry.Generation {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op for the in-memory backend.
+func (m *MemoryBackend) Close() error {
+	return nil
+}
@@ -1,79 +1,313 @@
 package collector
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
 	"github.com/stuttgart-things/machinery-status-collector/internal/registry"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// GitClient abstracts the GitHub operations needed by the Reconciler.
+// DefaultBranchName is the branch the reconciler pushes status updates to
+// unless overridden with WithBranchName.
+const DefaultBranchName = "machinery/status-updates"
+
+const prTitle = "chore: update claim statuses"
+
+// DeletePolicy controls how claims deleted in a cluster are reflected in the
+// registry.
+type DeletePolicy string
+
+const (
+	// DeletePolicyMark keeps the claim in the registry and sets deleted: true.
+	DeletePolicyMark DeletePolicy = "mark"
+	// DeletePolicyRemove drops the claim from the registry file.
+	DeletePolicyRemove DeletePolicy = "remove"
+)
+
+// ParseDeletePolicy validates a policy name.
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch p := DeletePolicy(s); p {
+	case DeletePolicyMark, DeletePolicyRemove:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown delete policy %q (must be mark or remove)", s)
+	}
+}
+
+// GitClient abstracts the Git provider operations needed by the Reconciler,
+// implemented for GitHub, GitLab, Gitea and plain git remotes.
 type GitClient interface {
 	FetchFile(path, ref string) ([]byte, string, error)
-	CreateBranch(baseSHA, branchName string) error
-	UpdateFile(path, branchName, message string, content []byte, sha string) error
+	// CommitFile commits content on top of baseSHA and force-moves branchName
+	// to the new commit, creating the branch if needed.
+	CommitFile(baseSHA, branchName, path, message string, content []byte) error
+	// CreatePR returns 0 if the provider has no pull requests, in which case
+	// the pushed commit is the whole update.
 	CreatePR(title, body, head, base string) (int, error)
+	UpdatePR(number int, title, body string) error
+	// ClosePR closes a pull request without merging it.
+	ClosePR(number int) error
 	ListOpenPRs(head string) ([]int, error)
 	GetRef(branch string) (string, error)
 }
 
 // Reconciler periodically checks the status store for dirty entries and
-// opens a PR with the updated registry YAML.
+// pushes the updated registry YAML to a single long-lived status PR.
 type Reconciler struct {
-	store        *StatusStore
-	gitClient    GitClient
-	interval     time.Duration
-	registryPath string
-	baseBranch   string
+	store         *StatusStore
+	gitClient     GitClient
+	interval      time.Duration
+	registryPath  string
+	baseBranch    string
+	branchName    string
+	skipUnchanged bool
+	autoRegister  bool
+	deletePolicy  DeletePolicy
+
+	// livenessIntervals is how many intervals the loop may go without a
+	// heartbeat before CheckHeartbeat fails.
+	livenessIntervals int
+	heartbeat         atomic.Int64
+
+	mu          sync.Mutex
+	registryErr error
+}
+
+// ReconcilerOption configures optional Reconciler behaviour.
+type ReconcilerOption func(*Reconciler)
+
+// WithBranchName sets the branch used for the status PR.
+func WithBranchName(name string) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.branchName = name
+	}
+}
+
+// WithSkipUnchanged controls whether a reconcile that does not change any
+// claim status is skipped (the default). When disabled, LastCheckedAt of every
+// reported claim is refreshed and a PR is pushed on each dirty tick.
+func WithSkipUnchanged(skip bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.skipUnchanged = skip
+	}
+}
+
+// WithAutoRegister enables appending claims that are not yet in the registry
+// instead of ignoring their status.
+func WithAutoRegister(enabled bool) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.autoRegister = enabled
+	}
+}
+
+// WithDeletePolicy sets how deleted claims are written to the registry.
+func WithDeletePolicy(policy DeletePolicy) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.deletePolicy = policy
+	}
+}
+
+// WithLivenessIntervals sets how many reconcile intervals may pass without a
+// loop iteration before CheckHeartbeat reports the reconciler as stuck. The
+// default is 3.
+func WithLivenessIntervals(n int) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.livenessIntervals = n
+	}
 }
 
 // NewReconciler creates a Reconciler that checks the store at the given interval.
-func NewReconciler(store *StatusStore, gitClient GitClient, interval time.Duration, registryPath, baseBranch string) *Reconciler {
-	return &Reconciler{
-		store:        store,
-		gitClient:    gitClient,
-		interval:     interval,
-		registryPath: registryPath,
-		baseBranch:   baseBranch,
+func NewReconciler(store *StatusStore, gitClient GitClient, interval time.Duration, registryPath, baseBranch string, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		store:         store,
+		gitClient:     gitClient,
+		interval:      interval,
+		registryPath:  registryPath,
+		baseBranch:    baseBranch,
+		branchName:    DefaultBranchName,
+		skipUnchanged: true,
+		deletePolicy:  DeletePolicyMark,
+
+		livenessIntervals: 3,
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	// Count from creation, so a loop that never starts is detected too.
+	r.beat()
+	return r
 }
 
 // Start runs the reconciliation loop until the context is cancelled.
 func (r *Reconciler) Start(ctx context.Context) {
 	ticker := time.NewTicker(r.interval)
 	defer ticker.Stop()
+	r.beat()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := r.reconcileOnce(ctx); err != nil {
+			start := time.Now()
+			err := r.reconcileOnce(ctx)
+			metrics.ObserveReconcile(time.Since(start), err)
+			if err != nil {
 				log.Printf("reconcile error: %v", err)
 			}
+			r.beat()
 		}
 	}
 }
 
-func (r *Reconciler) reconcileOnce(_ context.Context) error {
-	if !r.store.IsDirty() {
+func (r *Reconciler) beat() {
+	r.heartbeat.Store(time.Now().UnixNano())
+}
+
+// CheckHeartbeat reports an error if the reconcile loop has not completed an
+// iteration within the configured number of intervals, e.g. because a call
+// to GitHub hangs.
+func (r *Reconciler) CheckHeartbeat(ctx context.Context) error {
+	last := time.Unix(0, r.heartbeat.Load())
+	maxAge := time.Duration(r.livenessIntervals) * r.interval
+	if age := time.Since(last); age > maxAge {
+		return fmt.Errorf("last reconcile loop iteration %s ago, exceeds %s", age.Round(time.Second), maxAge)
+	}
+	return nil
+}
+
+// CheckRegistry reports the error of parsing the registry file on the last
+// fetch, if any. It passes until the registry has been fetched once.
+func (r *Reconciler) CheckRegistry(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.registryErr
+}
+
+func (r *Reconciler) setRegistryErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registryErr = err
+}
+
+// CheckGit verifies that the base branch can be resolved, which requires the
+// Git provider to be reachable and the token to be valid.
+func (r *Reconciler) CheckGit(ctx context.Context) error {
+	if _, err := r.gitClient.GetRef(r.baseBranch); err != nil {
+		return fmt.Errorf("resolve base branch %s: %w", r.baseBranch, err)
+	}
+	return nil
+}
+
+func (r *Reconciler) reconcileOnce(ctx context.Context) (err error) {
+	dirty, err := r.store.IsDirty()
+	if err != nil {
+		return fmt.Errorf("check dirty state: %w", err)
+	}
+	if !dirty {
 		return nil
 	}
 
-	yamlBytes, fileSHA, err := r.gitClient.FetchFile(r.registryPath, r.baseBranch)
+	ctx, span := tracing.Tracer().Start(ctx, "Reconciler.reconcile")
+	defer func() { tracing.End(span, err) }()
+	gitClient := tracedGitClient{ctx: ctx, next: r.gitClient}
+
+	baseSHA, err := gitClient.GetRef(r.baseBranch)
+	if err != nil {
+		return fmt.Errorf("get ref: %w", err)
+	}
+
+	// Read the registry at the exact commit the status branch is rebuilt on.
+	yamlBytes, _, err := gitClient.FetchFile(r.registryPath, baseSHA)
 	if err != nil {
 		return fmt.Errorf("fetch registry: %w", err)
 	}
 
 	reg, err := registry.ParseRegistry(yamlBytes)
+	if err != nil {
+		err = fmt.Errorf("parse registry: %w", err)
+		r.setRegistryErr(err)
+		return err
+	}
+	r.setRegistryErr(nil)
+	// Parse a second, untouched copy to diff the updated registry against.
+	baseReg, err := registry.ParseRegistry(yamlBytes)
 	if err != nil {
 		return fmt.Errorf("parse registry: %w", err)
 	}
 
-	for _, entry := range r.store.GetAll() {
-		registry.UpdateClaimStatus(reg, entry.Cluster, entry.ClaimRef, entry.StatusMessage)
+	// Entries above the previous watermark are the ones this run is for.
+	flushed, err := r.store.Flushed()
+	if err != nil {
+		return fmt.Errorf("read store: %w", err)
+	}
+	// Updates arriving after the snapshot carry a generation above the
+	// watermark, so they stay dirty and are picked up by the next tick.
+	entries, watermark, err := r.store.Snapshot()
+	if err != nil {
+		return fmt.Errorf("read store: %w", err)
+	}
+	linkIngestedEntries(span, entries, flushed)
+	span.SetAttributes(attribute.Int("store.entries", len(entries)), attribute.Int64("store.generation", int64(watermark)))
+	// Apply entries oldest first, so an entry stored without a kind by an
+	// older agent is superseded by a later one for the same claim.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Generation < entries[j].Generation })
+	var unknown int
+	for _, entry := range entries {
+		if entry.Deleted {
+			r.applyDeletion(reg, entry)
+			continue
+		}
+		if !registry.UpdateClaimStatus(reg, entry.Cluster, entry.Kind, entry.ClaimRef, entry.StatusMessage) {
+			if !r.autoRegister {
+				unknown++
+				continue
+			}
+			registry.AddClaim(reg, entry.Cluster, entry.Kind, entry.ClaimRef, entry.StatusMessage)
+		}
+		if entry.Kind != "" {
+			registry.SetClaimKind(reg, entry.Cluster, entry.Kind, entry.ClaimRef)
+		}
+		if len(entry.Conditions) > 0 {
+			registry.SetClaimConditions(reg, entry.Cluster, entry.Kind, entry.ClaimRef, entry.Conditions)
+		}
+		if !r.skipUnchanged {
+			registry.TouchClaim(reg, entry.Cluster, entry.Kind, entry.ClaimRef)
+		}
+	}
+	if unknown > 0 {
+		log.Printf("ignored status of %d claims not present in the registry", unknown)
+	}
+
+	openPRs, err := gitClient.ListOpenPRs(r.branchName)
+	if err != nil {
+		return fmt.Errorf("list open PRs: %w", err)
+	}
+
+	// Without semantic changes there is nothing to propose. An open PR would
+	// only keep proposing statuses that were reverted, so it is closed.
+	changes := registry.Diff(baseReg, reg)
+	if len(changes) == 0 && r.skipUnchanged {
+		for _, pr := range openPRs {
+			if err := gitClient.ClosePR(pr); err != nil {
+				return fmt.Errorf("close PR: %w", err)
+			}
+			log.Printf("closed PR #%d, registry already up to date", pr)
+		}
+		if len(openPRs) == 0 {
+			log.Printf("registry already up to date, skipping PR")
+		}
+		return r.flush(baseReg, entries, watermark)
 	}
 
 	updatedYAML, err := registry.SerializeRegistry(reg)
@@ -81,40 +315,116 @@ func (r *Reconciler) reconcileOnce(_ context.Context) error {
 		return fmt.Errorf("serialize registry: %w", err)
 	}
 
-	branchName := fmt.Sprintf("status-update-%d", time.Now().Unix())
+	// An open PR already proposing exactly this registry is left alone
+	// instead of being force-pushed and rewritten. If the status branch
+	// cannot be read it is rebuilt.
+	if len(openPRs) > 0 {
+		current, _, err := gitClient.FetchFile(r.registryPath, r.branchName)
+		if err == nil && bytes.Equal(current, updatedYAML) {
+			log.Printf("PR #%d already up to date", openPRs[0])
+			return r.flush(baseReg, entries, watermark)
+		}
+	}
 
-	openPRs, err := r.gitClient.ListOpenPRs(branchName)
-	if err != nil {
-		return fmt.Errorf("list open PRs: %w", err)
+	// The status branch is always rebuilt from the latest base: the store
+	// holds the full desired state, so earlier commits on it are superseded.
+	if err := gitClient.CommitFile(baseSHA, r.branchName, r.registryPath, prTitle, updatedYAML); err != nil {
+		return fmt.Errorf("commit file: %w", err)
 	}
+
+	body := prBody(changes)
 	if len(openPRs) > 0 {
-		return nil
+		if err := gitClient.UpdatePR(openPRs[0], prTitle, body); err != nil {
+			return fmt.Errorf("update PR: %w", err)
+		}
+		log.Printf("updated PR #%d on branch %s", openPRs[0], r.branchName)
+	} else {
+		prNum, err := gitClient.CreatePR(prTitle, body, r.branchName, r.baseBranch)
+		if err != nil {
+			return fmt.Errorf("create PR: %w", err)
+		}
+		if prNum == 0 {
+			log.Printf("pushed status update without a PR")
+		} else {
+			log.Printf("created PR #%d on branch %s", prNum, r.branchName)
+		}
 	}
 
-	commitSHA, err := r.gitClient.GetRef(r.baseBranch)
-	if err != nil {
-		return fmt.Errorf("get ref: %w", err)
+	return r.flush(baseReg, entries, watermark)
+}
+
+// flush marks the store flushed up to watermark. Tombstones are kept until
+// base, the registry the status branch was built on, reflects their deletion:
+// the branch is rebuilt from base and the store on every change, so a
+// deletion that is only proposed would otherwise get lost. They are purged on
+// the first reconcile after the deletion was merged.
+func (r *Reconciler) flush(base *registry.RegistryFile, entries []StatusEntry, watermark uint64) error {
+	if err := r.store.MarkFlushed(watermark); err != nil {
+		return fmt.Errorf("mark flushed: %w", err)
+	}
+	var merged []StatusEntry
+	for _, e := range entries {
+		if e.Deleted && e.Generation <= watermark && r.deletionMerged(base, e) {
+			merged = append(merged, e)
+		}
+	}
+	if err := r.store.Purge(merged); err != nil {
+		return fmt.Errorf("purge tombstones: %w", err)
 	}
+	return nil
+}
 
-	if err := r.gitClient.CreateBranch(commitSHA, branchName); err != nil {
-		return fmt.Errorf("create branch: %w", err)
+// deletionMerged reports whether reg already reflects the deletion recorded
+// by the tombstone entry under the delete policy.
+func (r *Reconciler) deletionMerged(reg *registry.RegistryFile, entry StatusEntry) bool {
+	claim := registry.FindClaim(reg, entry.Cluster, entry.Kind, entry.ClaimRef)
+	if claim == nil {
+		return true
 	}
+	return r.deletePolicy == DeletePolicyMark && claim.Deleted
+}
 
-	if err := r.gitClient.UpdateFile(r.registryPath, branchName, "chore: update claim statuses", updatedYAML, fileSHA); err != nil {
-		return fmt.Errorf("update file: %w", err)
+// applyDeletion writes a tombstone to the registry according to the delete
+// policy. Deleted claims missing from the registry are left alone.
+func (r *Reconciler) applyDeletion(reg *registry.RegistryFile, entry StatusEntry) {
+	if r.deletePolicy == DeletePolicyRemove {
+		registry.RemoveClaim(reg, entry.Cluster, entry.Kind, entry.ClaimRef)
+		return
 	}
+	registry.MarkClaimDeleted(reg, entry.Cluster, entry.Kind, entry.ClaimRef)
+}
 
-	prNum, err := r.gitClient.CreatePR(
-		"chore: update claim statuses",
-		"Automated status update from machinery-status-collector.",
-		branchName,
-		r.baseBranch,
-	)
-	if err != nil {
-		return fmt.Errorf("create PR: %w", err)
+// prBody renders the PR description, listing updated, newly registered and
+// deleted claims in separate sections.
+func prBody(changes []registry.ClaimChange) string {
+	var updated, added, deleted strings.Builder
+	for _, c := range changes {
+		switch {
+		case c.Type == registry.ChangeRemoved,
+			c.Type == registry.ChangeUpdated && c.After.Deleted && !c.Before.Deleted:
+			fmt.Fprintf(&deleted, "- `%s` / `%s`\n", c.Cluster, c.ClaimRef)
+		case c.Type == registry.ChangeUpdated && c.Before.StatusMessage == c.After.StatusMessage:
+			fmt.Fprintf(&updated, "- `%s` / `%s`: %s (conditions changed)\n", c.Cluster, c.ClaimRef, c.After.StatusMessage)
+		case c.Type == registry.ChangeUpdated:
+			fmt.Fprintf(&updated, "- `%s` / `%s`: %s → %s\n", c.Cluster, c.ClaimRef, c.Before.StatusMessage, c.After.StatusMessage)
+		case c.Type == registry.ChangeAdded:
+			fmt.Fprintf(&added, "- `%s` / `%s`: %s\n", c.Cluster, c.ClaimRef, c.After.StatusMessage)
+		}
 	}
 
-	log.Printf("created PR #%d on branch %s", prNum, branchName)
-	r.store.MarkFlushed()
-	return nil
+	var b strings.Builder
+	b.WriteString("Automated status update from machinery-status-collector.\n")
+	if updated.Len() > 0 {
+		b.WriteString("\n### Updated claims\n\n")
+		b.WriteString(updated.String())
+	}
+	if added.Len() > 0 {
+		b.WriteString("\n### Added claims\n\n")
+		b.WriteString(added.String())
+	}
+	if deleted.Len() > 0 {
+		b.WriteString("\n### Deleted claims\n\n")
+		b.WriteString(deleted.String())
+	}
+	return b.String()
 }
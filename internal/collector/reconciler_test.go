@@ -3,39 +3,70 @@ package collector
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/stuttgart-things/machinery-status-collector/internal/git"
+	"github.com/stuttgart-things/machinery-status-collector/internal/registry"
 )
 
 type mockGitClient struct {
 	fetchFileContent []byte
 	fetchFileSHA     string
 	fetchFileErr     error
+	// branchFiles holds the registry as committed on other branches, keyed
+	// by branch name. Fetching from any other ref than the base SHA fails
+	// with git.ErrNotFound.
+	branchFiles map[string][]byte
 
 	getRefSHA string
 	getRefErr error
 
-	createBranchErr error
-
-	updateFileErr error
+	commitFileErr error
+	// onCommitFile runs inside CommitFile to simulate work happening while
+	// the reconciler talks to GitHub.
+	onCommitFile func()
 
 	createPRNumber int
 	createPRErr    error
 
+	updatePRErr error
+
 	listOpenPRsNumbers []int
 	listOpenPRsErr     error
 
 	// Track calls for assertions.
-	fetchFileCalled  bool
-	getRefCalled     bool
-	createBranchName string
-	updateFileCalled bool
-	createPRCalled   bool
+	fetchFileCalled   bool
+	fetchFileRef      string
+	getRefCalled      bool
+	commitFileCalled  bool
+	commitFileBase    string
+	commitFileBranch  string
+	createPRCalled    bool
+	createPRBody      string
+	commitFileContent []byte
+	updatePRNumber    int
+	closedPRs         []int
 	listOpenPRsCalled bool
 }
 
 func (m *mockGitClient) FetchFile(path, ref string) ([]byte, string, error) {
+	if ref != m.getRefSHA {
+		if content, ok := m.branchFiles[ref]; ok {
+			return content, "", nil
+		}
+		return nil, "", git.ErrNotFound
+	}
 	m.fetchFileCalled = true
+	m.fetchFileRef = ref
 	return m.fetchFileContent, m.fetchFileSHA, m.fetchFileErr
 }
 
@@ -44,21 +75,33 @@ func (m *mockGitClient) GetRef(branch string) (string, error) {
 	return m.getRefSHA, m.getRefErr
 }
 
-func (m *mockGitClient) CreateBranch(baseSHA, branchName string) error {
-	m.createBranchName = branchName
-	return m.createBranchErr
-}
-
-func (m *mockGitClient) UpdateFile(path, branchName, message string, content []byte, sha string) error {
-	m.updateFileCalled = true
-	return m.updateFileErr
+func (m *mockGitClient) CommitFile(baseSHA, branchName, path, message string, content []byte) error {
+	m.commitFileCalled = true
+	m.commitFileBase = baseSHA
+	m.commitFileBranch = branchName
+	m.commitFileContent = content
+	if m.onCommitFile != nil {
+		m.onCommitFile()
+	}
+	return m.commitFileErr
 }
 
 func (m *mockGitClient) CreatePR(title, body, head, base string) (int, error) {
 	m.createPRCalled = true
+	m.createPRBody = body
 	return m.createPRNumber, m.createPRErr
 }
 
+func (m *mockGitClient) UpdatePR(number int, title, body string) error {
+	m.updatePRNumber = number
+	return m.updatePRErr
+}
+
+func (m *mockGitClient) ClosePR(number int) error {
+	m.closedPRs = append(m.closedPRs, number)
+	return nil
+}
+
 func (m *mockGitClient) ListOpenPRs(head string) ([]int, error) {
 	m.listOpenPRsCalled = true
 	return m.listOpenPRsNumbers, m.listOpenPRsErr
@@ -96,20 +139,49 @@ func TestReconcileOnce_DirtyStore(t *testing.T) {
 	if !mock.getRefCalled {
 		t.Fatal("expected GetRef to be called")
 	}
-	if mock.createBranchName == "" {
-		t.Fatal("expected CreateBranch to be called")
+	if mock.fetchFileRef != "commitsha456" {
+		t.Fatalf("expected registry to be fetched at base SHA, got %q", mock.fetchFileRef)
+	}
+	if !mock.commitFileCalled {
+		t.Fatal("expected CommitFile to be called")
 	}
-	if !mock.updateFileCalled {
-		t.Fatal("expected UpdateFile to be called")
+	if mock.commitFileBranch != DefaultBranchName || mock.commitFileBase != "commitsha456" {
+		t.Fatalf("expected commit on %q from base SHA, got %q from %q", DefaultBranchName, mock.commitFileBranch, mock.commitFileBase)
 	}
 	if !mock.createPRCalled {
 		t.Fatal("expected CreatePR to be called")
 	}
-	if store.IsDirty() {
+	if isDirty(t, store) {
 		t.Fatal("expected store to be flushed after successful reconcile")
 	}
 }
 
+func TestReconcileOnce_UpdateDuringReconcile(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "ready")
+
+	mock := &mockGitClient{
+		fetchFileContent:   []byte(testRegistryYAML),
+		fetchFileSHA:       "filesha123",
+		getRefSHA:          "commitsha456",
+		listOpenPRsNumbers: []int{},
+		createPRNumber:     7,
+		onCommitFile: func() {
+			store.Put("cluster-a", "my-claim-ref", "degraded")
+		},
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !isDirty(t, store) {
+		t.Fatal("expected update received mid-reconcile to keep the store dirty")
+	}
+}
+
 func TestReconcileOnce_CleanStore(t *testing.T) {
 	store := NewStatusStore()
 
@@ -126,33 +198,408 @@ func TestReconcileOnce_CleanStore(t *testing.T) {
 	}
 }
 
-func TestReconcileOnce_DuplicatePR(t *testing.T) {
+func TestReconcileOnce_ExistingPR(t *testing.T) {
 	store := NewStatusStore()
 	store.Put("cluster-a", "my-claim-ref", "ready")
 
 	mock := &mockGitClient{
 		fetchFileContent:   []byte(testRegistryYAML),
 		fetchFileSHA:       "filesha123",
+		getRefSHA:          "commitsha456",
 		listOpenPRsNumbers: []int{42},
 	}
 
-	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main", WithBranchName("status-branch"))
 
 	if err := rec.reconcileOnce(context.Background()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
+	if mock.commitFileBranch != "status-branch" || mock.commitFileBase != "commitsha456" {
+		t.Fatalf("expected status-branch to be rebuilt on base, got %q from %q", mock.commitFileBranch, mock.commitFileBase)
+	}
 	if !mock.listOpenPRsCalled {
 		t.Fatal("expected ListOpenPRs to be called")
 	}
-	if mock.getRefCalled {
-		t.Fatal("expected GetRef NOT to be called when duplicate PR exists")
+	if mock.createPRCalled {
+		t.Fatal("expected CreatePR NOT to be called when a PR is already open")
+	}
+	if mock.updatePRNumber != 42 {
+		t.Fatalf("expected PR #42 description to be updated, got #%d", mock.updatePRNumber)
+	}
+	if isDirty(t, store) {
+		t.Fatal("expected store to be flushed after updating the open PR")
+	}
+}
+
+func TestReconcileOnce_Unchanged(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "pending")
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mock.commitFileCalled || mock.createPRCalled {
+		t.Fatal("expected no commit or PR when no status changed")
+	}
+	if isDirty(t, store) {
+		t.Fatal("expected store to be flushed when the registry is already up to date")
+	}
+}
+
+func TestReconcileOnce_UnchangedWithOpenPR(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "pending")
+
+	mock := &mockGitClient{
+		fetchFileContent:   []byte(testRegistryYAML),
+		getRefSHA:          "commitsha456",
+		listOpenPRsNumbers: []int{42},
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mock.commitFileCalled || mock.createPRCalled {
+		t.Fatal("expected nothing to be committed when no status differs from base")
+	}
+	if len(mock.closedPRs) != 1 || mock.closedPRs[0] != 42 {
+		t.Fatalf("expected PR #42 to be closed, got %v", mock.closedPRs)
+	}
+	if isDirty(t, store) {
+		t.Fatal("expected store to be flushed when the registry is already up to date")
+	}
+}
+
+func TestReconcileOnce_OpenPRUpToDate(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "ready")
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+		createPRNumber:   42,
+	}
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The same status arrives again while the PR proposes it.
+	store.Put("cluster-a", "my-claim-ref", "ready")
+	mock = &mockGitClient{
+		fetchFileContent:   []byte(testRegistryYAML),
+		getRefSHA:          "commitsha456",
+		branchFiles:        map[string][]byte{DefaultBranchName: mock.commitFileContent},
+		listOpenPRsNumbers: []int{42},
+	}
+	rec.gitClient = mock
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
+
+	if mock.commitFileCalled || mock.updatePRNumber != 0 {
+		t.Fatal("expected an up-to-date PR not to be rewritten")
+	}
+	if isDirty(t, store) {
+		t.Fatal("expected store to be flushed when the PR is up to date")
+	}
+}
+
+func TestReconcileOnce_UnchangedSkipDisabled(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "pending")
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+		createPRNumber:   9,
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main", WithSkipUnchanged(false))
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !mock.commitFileCalled || !mock.createPRCalled {
+		t.Fatal("expected a PR refreshing timestamps when skipping is disabled")
+	}
+}
+
+func TestReconcileOnce_UnknownClaimIgnored(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-b", "default/new-claim", "ready")
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
 	if mock.createPRCalled {
-		t.Fatal("expected CreatePR NOT to be called when duplicate PR exists")
+		t.Fatal("expected no PR for unknown claims when auto-registration is disabled")
+	}
+}
+
+func TestReconcileOnce_AutoRegister(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "ready")
+	store.Put("cluster-b", "default/new-claim", "creating")
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+		createPRNumber:   7,
 	}
-	if !store.IsDirty() {
-		t.Fatal("expected store to remain dirty when PR creation is skipped")
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main", WithAutoRegister(true))
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !mock.createPRCalled {
+		t.Fatal("expected CreatePR to be called")
+	}
+	for _, want := range []string{
+		"### Updated claims",
+		"- `cluster-a` / `my-claim-ref`: pending → ready",
+		"### Added claims",
+		"- `cluster-b` / `default/new-claim`: creating",
+	} {
+		if !strings.Contains(mock.createPRBody, want) {
+			t.Errorf("expected PR body to contain %q, got:\n%s", want, mock.createPRBody)
+		}
+	}
+}
+
+func TestReconcileOnce_DeletePolicy(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy DeletePolicy
+		want   string
+		reject string
+	}{
+		{"mark", DeletePolicyMark, "deleted: true", ""},
+		{"remove", DeletePolicyRemove, "{}", "my-claim-ref"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewStatusStore()
+			store.Delete("cluster-a", "my-claim-ref")
+			store.Delete("cluster-b", "default/unknown-claim")
+
+			mock := &mockGitClient{
+				fetchFileContent: []byte(testRegistryYAML),
+				getRefSHA:        "commitsha456",
+				createPRNumber:   7,
+			}
+
+			rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main",
+				WithDeletePolicy(tc.policy), WithAutoRegister(true))
+
+			if err := rec.reconcileOnce(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			content := string(mock.commitFileContent)
+			if !strings.Contains(content, tc.want) {
+				t.Errorf("expected registry to contain %q, got:\n%s", tc.want, content)
+			}
+			if tc.reject != "" && strings.Contains(content, tc.reject) {
+				t.Errorf("expected registry NOT to contain %q, got:\n%s", tc.reject, content)
+			}
+			if strings.Contains(content, "unknown-claim") {
+				t.Error("expected deleted claims never to be auto-registered")
+			}
+			if !strings.Contains(mock.createPRBody, "### Deleted claims\n\n- `cluster-a` / `my-claim-ref`") {
+				t.Errorf("expected PR body to list the deleted claim, got:\n%s", mock.createPRBody)
+			}
+		})
+	}
+}
+
+func TestReconcileOnce_KeepsTombstoneUntilMerged(t *testing.T) {
+	store := NewStatusStore()
+	store.Delete("cluster-a", "my-claim-ref")
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+		createPRNumber:   42,
+	}
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main",
+		WithDeletePolicy(DeletePolicyRemove), WithAutoRegister(true))
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, _ := store.Get("cluster-a", "", "my-claim-ref"); !ok {
+		t.Fatal("expected the tombstone to be kept while the deletion is not merged")
+	}
+
+	// Another claim changes before the PR is merged.
+	store.Put("cluster-b", "default/new-claim", "ready")
+	mock = &mockGitClient{
+		fetchFileContent:   []byte(testRegistryYAML),
+		getRefSHA:          "commitsha456",
+		listOpenPRsNumbers: []int{42},
+	}
+	rec.gitClient = mock
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content := string(mock.commitFileContent)
+	if strings.Contains(content, "my-claim-ref") {
+		t.Errorf("expected the PR to keep removing the deleted claim, got:\n%s", content)
+	}
+	if !strings.Contains(content, "new-claim") {
+		t.Errorf("expected the PR to contain the new claim, got:\n%s", content)
+	}
+
+	// Once the PR is merged, the next reconcile purges the tombstone.
+	store.Put("cluster-b", "default/new-claim", "failed")
+	rec.gitClient = &mockGitClient{
+		fetchFileContent: mock.commitFileContent,
+		getRefSHA:        "commitsha789",
+		createPRNumber:   43,
+	}
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, _ := store.Get("cluster-a", "", "my-claim-ref"); ok {
+		t.Fatal("expected the tombstone to be purged once the deletion is merged")
+	}
+}
+
+func TestReconcileOnce_KindsShareClaimRef(t *testing.T) {
+	const registryYAML = `cluster-a:
+  - name: my-db
+    namespace: default
+    claimRef: default/my-db
+    kind: PostgreSQL
+    statusMessage: pending
+    lastCheckedAt: ""
+  - name: my-db
+    namespace: default
+    claimRef: default/my-db
+    kind: Bucket
+    statusMessage: pending
+    lastCheckedAt: ""
+`
+	store := NewStatusStore()
+	store.PutEntry(StatusEntry{Cluster: "cluster-a", Kind: "Bucket", ClaimRef: "default/my-db", StatusMessage: "ready"})
+	store.DeleteEntry(StatusEntry{Cluster: "cluster-a", Kind: "PostgreSQL", ClaimRef: "default/my-db"})
+
+	// Both entries are kept although they share cluster and claimRef.
+	if entries, _ := store.GetAll(); len(entries) != 2 {
+		t.Fatalf("expected 2 store entries, got %d", len(entries))
+	}
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(registryYAML),
+		getRefSHA:        "commitsha456",
+		createPRNumber:   7,
+	}
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main", WithDeletePolicy(DeletePolicyRemove))
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reg, err := registry.ParseRegistry(mock.commitFileContent)
+	if err != nil {
+		t.Fatalf("parse committed registry: %v", err)
+	}
+	claims := reg.Clusters["cluster-a"]
+	if len(claims) != 1 {
+		t.Fatalf("expected only the PostgreSQL claim to be removed, got %+v", claims)
+	}
+	if claims[0].Kind != "Bucket" || claims[0].StatusMessage != "ready" {
+		t.Errorf("expected the Bucket claim to be updated, got %+v", claims[0])
+	}
+}
+
+func TestReconcileOnce_Conditions(t *testing.T) {
+	store := NewStatusStore()
+	store.PutEntry(StatusEntry{
+		Cluster:       "cluster-a",
+		ClaimRef:      "my-claim-ref",
+		Kind:          "PostgreSQL",
+		StatusMessage: "pending",
+		Conditions: []registry.Condition{
+			{Type: "Synced", Status: "False", Reason: "ReconcileError", Message: "cannot apply"},
+		},
+	})
+
+	mock := &mockGitClient{
+		fetchFileContent: []byte(testRegistryYAML),
+		getRefSHA:        "commitsha456",
+		createPRNumber:   7,
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !mock.commitFileCalled {
+		t.Fatal("expected a condition change to be committed even though the status message is unchanged")
+	}
+	reg, err := registry.ParseRegistry(mock.commitFileContent)
+	if err != nil {
+		t.Fatalf("parse committed registry: %v", err)
+	}
+	claim := reg.Clusters["cluster-a"][0]
+	if claim.Kind != "PostgreSQL" {
+		t.Errorf("expected kind 'PostgreSQL', got %q", claim.Kind)
+	}
+	if claim.Synced == nil || *claim.Synced || claim.Ready == nil || *claim.Ready {
+		t.Errorf("expected ready=false synced=false, got ready=%v synced=%v", claim.Ready, claim.Synced)
+	}
+	if len(claim.Conditions) != 1 || claim.Conditions[0].Reason != "ReconcileError" {
+		t.Errorf("unexpected conditions: %+v", claim.Conditions)
+	}
+	if !strings.Contains(mock.createPRBody, "- `cluster-a` / `my-claim-ref`: pending (conditions changed)") {
+		t.Errorf("expected PR body to list the condition change, got:\n%s", mock.createPRBody)
+	}
+}
+
+func TestReconcileOnce_GetRefError(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "ready")
+
+	mock := &mockGitClient{
+		getRefErr: fmt.Errorf("network error"),
+	}
+
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.reconcileOnce(context.Background()); err == nil {
+		t.Fatal("expected error when GetRef fails")
+	}
+	if mock.fetchFileCalled {
+		t.Fatal("expected FetchFile NOT to be called when GetRef fails")
+	}
+	if !isDirty(t, store) {
+		t.Fatal("expected store to remain dirty after error")
 	}
 }
 
@@ -161,6 +608,7 @@ func TestReconcileOnce_FetchFileError(t *testing.T) {
 	store.Put("cluster-a", "my-claim-ref", "ready")
 
 	mock := &mockGitClient{
+		getRefSHA:    "commitsha456",
 		fetchFileErr: fmt.Errorf("network error"),
 	}
 
@@ -170,7 +618,7 @@ func TestReconcileOnce_FetchFileError(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error when FetchFile fails")
 	}
-	if !store.IsDirty() {
+	if !isDirty(t, store) {
 		t.Fatal("expected store to remain dirty after error")
 	}
 }
@@ -198,3 +646,155 @@ func TestStartAndStop(t *testing.T) {
 		t.Fatal("expected Start to return after context cancellation")
 	}
 }
+
+func TestCheckHeartbeat(t *testing.T) {
+	rec := NewReconciler(NewStatusStore(), &mockGitClient{}, 50*time.Millisecond, "registry.yaml", "main",
+		WithLivenessIntervals(2))
+
+	if err := rec.CheckHeartbeat(context.Background()); err != nil {
+		t.Fatalf("expected fresh reconciler to be alive, got %v", err)
+	}
+	time.Sleep(120 * time.Millisecond)
+	if err := rec.CheckHeartbeat(context.Background()); err == nil {
+		t.Fatal("expected error when the loop has not run for more than 2 intervals")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rec.Start(ctx)
+	time.Sleep(10 * time.Millisecond)
+	if err := rec.CheckHeartbeat(context.Background()); err != nil {
+		t.Fatalf("expected running reconciler to be alive, got %v", err)
+	}
+}
+
+func TestCheckRegistry(t *testing.T) {
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "ready")
+	mock := &mockGitClient{
+		getRefSHA:        "commitsha456",
+		fetchFileContent: []byte("cluster-a: [unterminated"),
+	}
+	rec := NewReconciler(store, mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.CheckRegistry(context.Background()); err != nil {
+		t.Fatalf("expected no error before the first fetch, got %v", err)
+	}
+	if err := rec.reconcileOnce(context.Background()); err == nil {
+		t.Fatal("expected reconcile to fail on invalid registry")
+	}
+	if err := rec.CheckRegistry(context.Background()); err == nil {
+		t.Fatal("expected error after fetching an invalid registry")
+	}
+
+	mock.fetchFileContent = []byte(testRegistryYAML)
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := rec.CheckRegistry(context.Background()); err != nil {
+		t.Fatalf("expected no error after a valid fetch, got %v", err)
+	}
+}
+
+func TestCheckGit(t *testing.T) {
+	mock := &mockGitClient{getRefErr: fmt.Errorf("401 Bad credentials")}
+	rec := NewReconciler(NewStatusStore(), mock, time.Minute, "registry.yaml", "main")
+
+	if err := rec.CheckGit(context.Background()); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Fatalf("expected GetRef error, got %v", err)
+	}
+	mock.getRefErr = nil
+	if err := rec.CheckGit(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestReconcileOnce_PlainGit runs the reconciler end to end against a local
+// bare repository.
+func TestReconcileOnce_PlainGit(t *testing.T) {
+	// Serve file:// remotes in process, so the test needs no git binary.
+	client.InstallProtocol("file", server.DefaultServer)
+
+	// Seed the remote with the registry on main.
+	remote := t.TempDir()
+	if _, err := gogit.PlainInit(remote, true); err != nil {
+		t.Fatalf("init remote: %v", err)
+	}
+	src := t.TempDir()
+	seed, err := gogit.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("init source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "registry.yaml"), []byte(testRegistryYAML), 0o644); err != nil {
+		t.Fatalf("write registry: %v", err)
+	}
+	wt, err := seed.Worktree()
+	if err != nil {
+		t.Fatalf("worktree: %v", err)
+	}
+	if _, err := wt.Add("registry.yaml"); err != nil {
+		t.Fatalf("add registry: %v", err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("initial", &gogit.CommitOptions{Author: sig}); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := seed.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remote}}); err != nil {
+		t.Fatalf("create remote: %v", err)
+	}
+	if err := seed.Push(&gogit.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/main"}}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+
+	gitClient, err := git.NewPlainGitClient(remote, t.TempDir())
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	store := NewStatusStore()
+	store.Put("cluster-a", "my-claim-ref", "ready")
+	rec := NewReconciler(store, gitClient, time.Minute, "registry.yaml", "main", WithAutoRegister(true))
+
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, _, err := gitClient.FetchFile("registry.yaml", DefaultBranchName)
+	if err != nil {
+		t.Fatalf("fetch status branch: %v", err)
+	}
+	reg, err := registry.ParseRegistry(content)
+	if err != nil {
+		t.Fatalf("parse registry: %v", err)
+	}
+	if got := reg.Clusters["cluster-a"][0].StatusMessage; got != "ready" {
+		t.Fatalf("expected status %q on %s, got %q", "ready", DefaultBranchName, got)
+	}
+	if isDirty(t, store) {
+		t.Fatal("expected store to be flushed after successful reconcile")
+	}
+
+	// A deletion followed by an unrelated update: the status branch is
+	// rebuilt from main, which does not have the deletion yet.
+	store.Delete("cluster-a", "my-claim-ref")
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.Put("cluster-b", "default/other-claim", "ready")
+	if err := rec.reconcileOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, _, err = gitClient.FetchFile("registry.yaml", DefaultBranchName)
+	if err != nil {
+		t.Fatalf("fetch status branch: %v", err)
+	}
+	if reg, err = registry.ParseRegistry(content); err != nil {
+		t.Fatalf("parse registry: %v", err)
+	}
+	if claim := registry.FindClaim(reg, "cluster-a", "", "my-claim-ref"); claim == nil || !claim.Deleted {
+		t.Fatalf("expected the deletion to survive the rebuild, got %+v", claim)
+	}
+	if registry.FindClaim(reg, "cluster-b", "", "default/other-claim") == nil {
+		t.Fatal("expected the unrelated update on the status branch")
+	}
+}
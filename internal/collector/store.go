@@ -1,79 +1,229 @@
 package collector
 
 import (
-	"sync"
+	"context"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/registry"
 )
 
 // StatusEntry represents a status update received from a cluster agent.
 type StatusEntry struct {
-	Cluster       string
-	ClaimRef      string
-	StatusMessage string
-	ReceivedAt    time.Time
+	Cluster       string               `json:"cluster"`
+	ClaimRef      string               `json:"claimRef"`
+	Kind          string               `json:"kind,omitempty"`
+	StatusMessage string               `json:"statusMessage"`
+	Conditions    []registry.Condition `json:"conditions,omitempty"`
+	Ready         bool                 `json:"ready"`
+	Synced        bool                 `json:"synced"`
+	ReceivedAt    time.Time            `json:"receivedAt"`
+	Generation    uint64               `json:"generation"`
+	// Deleted marks a tombstone recorded when the claim was deleted.
+	Deleted bool `json:"deleted,omitempty"`
+	// TraceParent is the W3C traceparent of the request that ingested the
+	// entry, so the reconcile that picks it up can link back to it.
+	TraceParent string `json:"traceParent,omitempty"`
 }
 
-// StatusStore is a thread-safe in-memory store for collecting status updates.
-// It tracks dirty state to signal when a reconciliation PR is needed.
+// ErrStoreFull is returned by writes that would grow the store beyond the
+// capacity of its backend.
+var ErrStoreFull = errors.New("status store is full")
+
+// Backend is the storage interface behind a StatusStore. Implementations must
+// be safe for concurrent use.
+//
+// Every Put assigns the entry the next value of a monotonically increasing
+// generation counter. An entry is dirty while its generation is above the
+// flushed watermark set by MarkFlushed. PutBatch stores several entries in a
+// single write, assigning them consecutive generations in order. Purge
+// removes entries in a single write, skipping those updated since they were
+// read.
+type Backend interface {
+	Put(entry StatusEntry) error
+	PutBatch(entries []StatusEntry) error
+	Get(cluster, kind, claimRef string) (StatusEntry, bool, error)
+	GetAll() ([]StatusEntry, error)
+	Generation() (uint64, error)
+	Flushed() (uint64, error)
+	IsDirty() (bool, error)
+	MarkFlushed(generation uint64) error
+	Purge(entries []StatusEntry) error
+	Close() error
+}
+
+// StatusStore collects status updates from cluster agents and tracks which
+// of them still need to be reconciled into a PR. Entries are kept in a
+// pluggable Backend.
 type StatusStore struct {
-	sync.RWMutex
-	entries map[string]StatusEntry
-	dirty   bool
+	backend Backend
 }
 
-// NewStatusStore creates an empty StatusStore.
+// NewStatusStore creates an empty StatusStore backed by memory.
 func NewStatusStore() *StatusStore {
-	return &StatusStore{
-		entries: make(map[string]StatusEntry),
-	}
+	return NewStatusStoreWithBackend(NewMemoryBackend())
 }
 
-func storeKey(cluster, claimRef string) string {
-	return cluster + "/" + claimRef
+// NewStatusStoreWithBackend creates a StatusStore on top of the given backend.
+func NewStatusStoreWithBackend(backend Backend) *StatusStore {
+	return &StatusStore{backend: backend}
 }
 
-// Put inserts or updates a status entry and marks the store as dirty.
-func (s *StatusStore) Put(cluster, claimRef, status string) {
-	s.Lock()
-	defer s.Unlock()
-	s.entries[storeKey(cluster, claimRef)] = StatusEntry{
+// storeKey identifies a claim. Claims of different kinds may share a
+// namespace and name, so the kind is part of the key.
+func storeKey(cluster, kind, claimRef string) string {
+	return cluster + "/" + kind + "/" + claimRef
+}
+
+// Put inserts or updates a status entry with a new generation, marking it dirty.
+func (s *StatusStore) Put(cluster, claimRef, status string) error {
+	return s.PutEntry(StatusEntry{
 		Cluster:       cluster,
 		ClaimRef:      claimRef,
 		StatusMessage: status,
-		ReceivedAt:    time.Now().UTC(),
+	})
+}
+
+// PutEntry stores a full status entry, including its conditions. Ready and
+// Synced are derived from the conditions and ReceivedAt is set to now.
+func (s *StatusStore) PutEntry(entry StatusEntry) error {
+	return s.backend.Put(statusEntry(entry))
+}
+
+// Delete records a tombstone for a deleted claim, replacing any previous
+// status. The tombstone is dirty like a regular update.
+func (s *StatusStore) Delete(cluster, claimRef string) error {
+	return s.DeleteEntry(StatusEntry{Cluster: cluster, ClaimRef: claimRef})
+}
+
+// DeleteEntry records a tombstone for the claim identified by entry. Only
+// its Cluster, Kind, ClaimRef and TraceParent are kept.
+func (s *StatusStore) DeleteEntry(entry StatusEntry) error {
+	return s.backend.Put(tombstone(entry))
+}
+
+// PutBatch stores several entries in a single backend write. Entries with
+// Deleted set are recorded as tombstones like DeleteEntry does, all others
+// are stored like PutEntry does.
+func (s *StatusStore) PutBatch(entries []StatusEntry) error {
+	batch := make([]StatusEntry, len(entries))
+	for i, e := range entries {
+		if e.Deleted {
+			batch[i] = tombstone(e)
+		} else {
+			batch[i] = statusEntry(e)
+		}
+	}
+	return s.backend.PutBatch(batch)
+}
+
+// statusEntry prepares entry for PutEntry.
+func statusEntry(entry StatusEntry) StatusEntry {
+	entry.Ready = registry.IsConditionTrue(entry.Conditions, "Ready")
+	entry.Synced = registry.IsConditionTrue(entry.Conditions, "Synced")
+	entry.ReceivedAt = time.Now().UTC()
+	entry.Deleted = false
+	return entry
+}
+
+// tombstone prepares entry for DeleteEntry.
+func tombstone(entry StatusEntry) StatusEntry {
+	return StatusEntry{
+		Cluster:     entry.Cluster,
+		Kind:        entry.Kind,
+		ClaimRef:    entry.ClaimRef,
+		ReceivedAt:  time.Now().UTC(),
+		Deleted:     true,
+		TraceParent: entry.TraceParent,
 	}
-	s.dirty = true
 }
 
-// Get retrieves a status entry by cluster and claimRef.
-func (s *StatusStore) Get(cluster, claimRef string) (StatusEntry, bool) {
-	s.RLock()
-	defer s.RUnlock()
-	e, ok := s.entries[storeKey(cluster, claimRef)]
-	return e, ok
+// Get retrieves a status entry by cluster, kind and claimRef.
+func (s *StatusStore) Get(cluster, kind, claimRef string) (StatusEntry, bool, error) {
+	return s.backend.Get(cluster, kind, claimRef)
 }
 
 // GetAll returns a snapshot copy of all entries in the store.
-func (s *StatusStore) GetAll() []StatusEntry {
-	s.RLock()
-	defer s.RUnlock()
-	result := make([]StatusEntry, 0, len(s.entries))
-	for _, e := range s.entries {
-		result = append(result, e)
+func (s *StatusStore) GetAll() ([]StatusEntry, error) {
+	return s.backend.GetAll()
+}
+
+// Snapshot returns all entries together with a generation watermark. Every
+// entry with a generation at or below the watermark is part of the snapshot;
+// entries written concurrently may also be included but carry a higher
+// generation, so passing the watermark to MarkFlushed never drops them.
+func (s *StatusStore) Snapshot() ([]StatusEntry, uint64, error) {
+	watermark, err := s.backend.Generation()
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := s.backend.GetAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, watermark, nil
+}
+
+// Generation returns the generation assigned by the most recent Put.
+func (s *StatusStore) Generation() (uint64, error) {
+	return s.backend.Generation()
+}
+
+// Flushed returns the flushed watermark set by MarkFlushed.
+func (s *StatusStore) Flushed() (uint64, error) {
+	return s.backend.Flushed()
+}
+
+// IsDirty reports whether any entry has a generation above the flushed watermark.
+func (s *StatusStore) IsDirty() (bool, error) {
+	return s.backend.IsDirty()
+}
+
+// Stats returns the number of entries in the store and how many of them are
+// dirty.
+func (s *StatusStore) Stats() (entries, dirty int, err error) {
+	flushed, err := s.backend.Flushed()
+	if err != nil {
+		return 0, 0, err
+	}
+	all, err := s.backend.GetAll()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, e := range all {
+		if e.Generation > flushed {
+			dirty++
+		}
+	}
+	return len(all), dirty, nil
+}
+
+// Check reports an error if the backend cannot be read. It matches the
+// signature of a health check.
+func (s *StatusStore) Check(ctx context.Context) error {
+	if _, err := s.backend.Generation(); err != nil {
+		return fmt.Errorf("read store: %w", err)
 	}
-	return result
+	return nil
 }
 
-// IsDirty reports whether the store has been modified since the last flush.
-func (s *StatusStore) IsDirty() bool {
-	s.RLock()
-	defer s.RUnlock()
-	return s.dirty
+// MarkFlushed marks all entries up to and including the given generation as
+// flushed. Entries written after the watermark was taken stay dirty.
+func (s *StatusStore) MarkFlushed(generation uint64) error {
+	return s.backend.MarkFlushed(generation)
+}
+
+// Purge removes entries, typically tombstones that are no longer needed. An
+// entry updated since it was read is kept, so no newer status is lost.
+func (s *StatusStore) Purge(entries []StatusEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.backend.Purge(entries)
 }
 
-// MarkFlushed resets the dirty flag.
-func (s *StatusStore) MarkFlushed() {
-	s.Lock()
-	defer s.Unlock()
-	s.dirty = false
+// Close releases the resources held by the backend.
+func (s *StatusStore) Close() error {
+	return s.backend.Close()
 }
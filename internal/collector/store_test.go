@@ -1,16 +1,33 @@
 package collector
 
 import (
+	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/registry"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
+func isDirty(t *testing.T, s *StatusStore) bool {
+	t.Helper()
+	dirty, err := s.IsDirty()
+	if err != nil {
+		t.Fatalf("IsDirty: %v", err)
+	}
+	return dirty
+}
+
 func TestPutAndGet(t *testing.T) {
 	s := NewStatusStore()
 
 	s.Put("cluster-01", "postgresqls.2.2.2/my-db", "Ready")
 
-	e, ok := s.Get("cluster-01", "postgresqls.2.2.2/my-db")
+	e, ok, err := s.Get("cluster-01", "", "postgresqls.2.2.2/my-db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if !ok {
 		t.Fatal("expected entry to exist")
 	}
@@ -27,7 +44,7 @@ func TestPutAndGet(t *testing.T) {
 		t.Error("expected ReceivedAt to be set")
 	}
 
-	_, ok = s.Get("cluster-01", "nonexistent")
+	_, ok, _ = s.Get("cluster-01", "", "nonexistent")
 	if ok {
 		t.Error("expected entry to not exist")
 	}
@@ -36,26 +53,271 @@ func TestPutAndGet(t *testing.T) {
 func TestDirtyFlag(t *testing.T) {
 	s := NewStatusStore()
 
-	if s.IsDirty() {
+	if isDirty(t, s) {
 		t.Fatal("new store should not be dirty")
 	}
 
 	s.Put("cluster-01", "postgresqls.2.2.2/my-db", "Ready")
-	if !s.IsDirty() {
+	if !isDirty(t, s) {
 		t.Fatal("store should be dirty after Put")
 	}
 
-	s.MarkFlushed()
-	if s.IsDirty() {
+	gen, _ := s.Generation()
+	s.MarkFlushed(gen)
+	if isDirty(t, s) {
 		t.Fatal("store should not be dirty after MarkFlushed")
 	}
 
 	s.Put("cluster-01", "postgresqls.2.2.2/my-db", "Degraded")
-	if !s.IsDirty() {
+	if !isDirty(t, s) {
 		t.Fatal("store should be dirty after second Put")
 	}
 }
 
+func TestGenerations(t *testing.T) {
+	s := NewStatusStore()
+
+	s.Put("cluster-01", "claim/a", "Ready")
+	s.Put("cluster-01", "claim/b", "Ready")
+	s.Put("cluster-01", "claim/a", "Degraded")
+
+	a, _, _ := s.Get("cluster-01", "", "claim/a")
+	b, _, _ := s.Get("cluster-01", "", "claim/b")
+	if a.Generation != 3 {
+		t.Errorf("expected claim/a generation 3, got %d", a.Generation)
+	}
+	if b.Generation != 2 {
+		t.Errorf("expected claim/b generation 2, got %d", b.Generation)
+	}
+}
+
+func TestMarkFlushed_KeepsNewerEntriesDirty(t *testing.T) {
+	s := NewStatusStore()
+	s.Put("cluster-01", "claim/a", "Ready")
+
+	_, watermark, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	// Arrives while the reconciler is busy with the snapshot.
+	s.Put("cluster-01", "claim/b", "Pending")
+
+	s.MarkFlushed(watermark)
+	if !isDirty(t, s) {
+		t.Fatal("store should stay dirty for updates newer than the watermark")
+	}
+
+	// An older watermark must not move the flushed state backwards.
+	gen, _ := s.Generation()
+	s.MarkFlushed(gen)
+	s.MarkFlushed(watermark)
+	if isDirty(t, s) {
+		t.Fatal("store should not be dirty after flushing the latest generation")
+	}
+}
+
+// TestConcurrentSnapshotAndFlush runs writers against a simulated reconciler
+// and checks that the final state of every claim is included in some flushed
+// snapshot.
+func TestConcurrentSnapshotAndFlush(t *testing.T) {
+	s := NewStatusStore()
+
+	const writers, updates = 8, 200
+
+	flushed := make(map[string]string)
+	flush := func() {
+		entries, watermark, err := s.Snapshot()
+		if err != nil {
+			t.Errorf("snapshot: %v", err)
+			return
+		}
+		for _, e := range entries {
+			flushed[e.ClaimRef] = e.StatusMessage
+		}
+		s.MarkFlushed(watermark)
+	}
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range updates {
+				s.Put("cluster-01", fmt.Sprintf("claim/%d", w), fmt.Sprintf("status-%d", i))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			flush()
+		}
+	}
+
+	// Drain whatever the writers left dirty, exactly like later ticks would.
+	for isDirty(t, s) {
+		flush()
+	}
+
+	for w := range writers {
+		claimRef := fmt.Sprintf("claim/%d", w)
+		want := fmt.Sprintf("status-%d", updates-1)
+		if got := flushed[claimRef]; got != want {
+			t.Errorf("%s: expected last flushed status %q, got %q", claimRef, want, got)
+		}
+	}
+}
+
+func TestPutEntry_DerivesReadySynced(t *testing.T) {
+	s := NewStatusStore()
+	err := s.PutEntry(StatusEntry{
+		Cluster:       "cluster-01",
+		ClaimRef:      "claim/a",
+		StatusMessage: "Available",
+		Conditions: []registry.Condition{
+			{Type: "Ready", Status: "True", Reason: "Available"},
+			{Type: "Synced", Status: "False", Reason: "ReconcileError"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("put entry: %v", err)
+	}
+
+	e, _, _ := s.Get("cluster-01", "", "claim/a")
+	if !e.Ready || e.Synced {
+		t.Errorf("expected ready=true synced=false, got ready=%v synced=%v", e.Ready, e.Synced)
+	}
+	if len(e.Conditions) != 2 {
+		t.Errorf("expected 2 conditions, got %d", len(e.Conditions))
+	}
+	if e.ReceivedAt.IsZero() {
+		t.Error("expected ReceivedAt to be set")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := NewStatusStore()
+	s.Put("cluster-01", "claim/a", "Ready")
+	gen, _ := s.Generation()
+	s.MarkFlushed(gen)
+
+	if err := s.Delete("cluster-01", "claim/a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if !isDirty(t, s) {
+		t.Fatal("store should be dirty after Delete")
+	}
+
+	e, ok, _ := s.Get("cluster-01", "", "claim/a")
+	if !ok || !e.Deleted {
+		t.Fatalf("expected tombstone, got %+v (found=%v)", e, ok)
+	}
+
+	// A claim recreated after deletion replaces the tombstone.
+	s.Put("cluster-01", "claim/a", "Creating")
+	e, _, _ = s.Get("cluster-01", "", "claim/a")
+	if e.Deleted {
+		t.Fatal("expected Put to replace the tombstone")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	backends := map[string]func(t *testing.T) Backend{
+		"memory": func(t *testing.T) Backend { return NewMemoryBackend() },
+		"bolt": func(t *testing.T) Backend {
+			b, err := NewBoltBackend(filepath.Join(t.TempDir(), "status.db"))
+			if err != nil {
+				t.Fatalf("open backend: %v", err)
+			}
+			return b
+		},
+		"configmap": func(t *testing.T) Backend {
+			b, err := NewConfigMapBackend(fake.NewClientset(), "default", "status-store")
+			if err != nil {
+				t.Fatalf("create backend: %v", err)
+			}
+			return b
+		},
+	}
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := NewStatusStoreWithBackend(newBackend(t))
+			defer s.Close()
+
+			s.Put("cluster-01", "claim/a", "Ready")
+			s.Delete("cluster-01", "claim/gone")
+			s.Delete("cluster-01", "claim/back")
+			gone, _, _ := s.Get("cluster-01", "", "claim/gone")
+			back, _, _ := s.Get("cluster-01", "", "claim/back")
+			// The claim is recreated after its tombstone was read.
+			s.Put("cluster-01", "claim/back", "Creating")
+
+			if err := s.Purge([]StatusEntry{gone, back}); err != nil {
+				t.Fatalf("purge: %v", err)
+			}
+			if _, ok, _ := s.Get("cluster-01", "", "claim/gone"); ok {
+				t.Error("expected the tombstone to be purged")
+			}
+			if e, ok, _ := s.Get("cluster-01", "", "claim/back"); !ok || e.Deleted {
+				t.Error("expected the entry updated since it was read to be kept")
+			}
+			if _, ok, _ := s.Get("cluster-01", "", "claim/a"); !ok {
+				t.Error("expected other entries to be kept")
+			}
+		})
+	}
+}
+
+func TestPutBatch(t *testing.T) {
+	s := NewStatusStore()
+	s.Put("cluster-01", "claim/gone", "Ready")
+
+	err := s.PutBatch([]StatusEntry{
+		{Cluster: "cluster-01", ClaimRef: "claim/a", StatusMessage: "Ready", Conditions: []registry.Condition{{Type: "Ready", Status: "True"}}},
+		{Cluster: "cluster-01", ClaimRef: "claim/gone", StatusMessage: "ignored", Deleted: true},
+	})
+	if err != nil {
+		t.Fatalf("put batch: %v", err)
+	}
+
+	a, _, _ := s.Get("cluster-01", "", "claim/a")
+	if a.Generation != 2 || !a.Ready || a.ReceivedAt.IsZero() {
+		t.Errorf("expected claim/a stored like PutEntry at generation 2, got %+v", a)
+	}
+	gone, _, _ := s.Get("cluster-01", "", "claim/gone")
+	if gone.Generation != 3 || !gone.Deleted || gone.StatusMessage != "" {
+		t.Errorf("expected a tombstone for claim/gone at generation 3, got %+v", gone)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := NewStatusStore()
+	s.Put("cluster-01", "claim/a", "Ready")
+	s.Put("cluster-01", "claim/b", "Ready")
+	gen, _ := s.Generation()
+	s.MarkFlushed(gen)
+	s.Put("cluster-01", "claim/b", "Failed")
+	s.Put("cluster-02", "claim/c", "Ready")
+
+	entries, dirty, err := s.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if entries != 3 || dirty != 2 {
+		t.Fatalf("expected 3 entries and 2 dirty, got %d and %d", entries, dirty)
+	}
+}
+
 func TestConcurrentPut(t *testing.T) {
 	s := NewStatusStore()
 	var wg sync.WaitGroup
@@ -65,7 +327,7 @@ func TestConcurrentPut(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			s.Put("cluster-01", "claim/ref", "status")
-			s.Get("cluster-01", "claim/ref")
+			s.Get("cluster-01", "", "claim/ref")
 			s.IsDirty()
 			s.GetAll()
 		}()
@@ -73,7 +335,7 @@ func TestConcurrentPut(t *testing.T) {
 
 	wg.Wait()
 
-	e, ok := s.Get("cluster-01", "claim/ref")
+	e, ok, _ := s.Get("cluster-01", "", "claim/ref")
 	if !ok {
 		t.Fatal("expected entry to exist after concurrent writes")
 	}
@@ -87,7 +349,10 @@ func TestGetAllReturnsSnapshot(t *testing.T) {
 	s.Put("cluster-01", "claim/a", "Ready")
 	s.Put("cluster-02", "claim/b", "Pending")
 
-	snapshot := s.GetAll()
+	snapshot, err := s.GetAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if len(snapshot) != 2 {
 		t.Fatalf("expected 2 entries, got %d", len(snapshot))
 	}
@@ -95,7 +360,7 @@ func TestGetAllReturnsSnapshot(t *testing.T) {
 	// Mutating the returned slice should not affect the store.
 	snapshot[0].StatusMessage = "MODIFIED"
 
-	e, _ := s.Get(snapshot[0].Cluster, snapshot[0].ClaimRef)
+	e, _, _ := s.Get(snapshot[0].Cluster, snapshot[0].Kind, snapshot[0].ClaimRef)
 	if e.StatusMessage == "MODIFIED" {
 		t.Error("GetAll must return a copy; store was mutated via returned slice")
 	}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxReconcileLinks caps the links from a reconcile span to the requests that
+// ingested its entries; tracing backends drop excess links anyway.
+const maxReconcileLinks = 128
+
+// tracedGitClient wraps every call to a GitClient in a span that is a child
+// of the reconcile span in ctx. It is created per reconcile run.
+type tracedGitClient struct {
+	ctx  context.Context
+	next GitClient
+}
+
+func (c tracedGitClient) start(name string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := tracing.Tracer().Start(c.ctx, "GitClient."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return span
+}
+
+func (c tracedGitClient) FetchFile(path, ref string) ([]byte, string, error) {
+	span := c.start("FetchFile", attribute.String("git.path", path), attribute.String("git.ref", ref))
+	content, sha, err := c.next.FetchFile(path, ref)
+	tracing.End(span, err)
+	return content, sha, err
+}
+
+func (c tracedGitClient) CommitFile(baseSHA, branchName, path, message string, content []byte) error {
+	span := c.start("CommitFile", attribute.String("git.branch", branchName), attribute.String("git.path", path))
+	err := c.next.CommitFile(baseSHA, branchName, path, message, content)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedGitClient) CreatePR(title, body, head, base string) (int, error) {
+	span := c.start("CreatePR", attribute.String("git.head", head), attribute.String("git.base", base))
+	number, err := c.next.CreatePR(title, body, head, base)
+	span.SetAttributes(attribute.Int("git.pr", number))
+	tracing.End(span, err)
+	return number, err
+}
+
+func (c tracedGitClient) UpdatePR(number int, title, body string) error {
+	span := c.start("UpdatePR", attribute.Int("git.pr", number))
+	err := c.next.UpdatePR(number, title, body)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedGitClient) ClosePR(number int) error {
+	span := c.start("ClosePR", attribute.Int("git.pr", number))
+	err := c.next.ClosePR(number)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedGitClient) ListOpenPRs(head string) ([]int, error) {
+	span := c.start("ListOpenPRs", attribute.String("git.head", head))
+	prs, err := c.next.ListOpenPRs(head)
+	tracing.End(span, err)
+	return prs, err
+}
+
+func (c tracedGitClient) GetRef(branch string) (string, error) {
+	span := c.start("GetRef", attribute.String("git.ref", branch))
+	sha, err := c.next.GetRef(branch)
+	tracing.End(span, err)
+	return sha, err
+}
+
+// linkIngestedEntries links span to the ingesting requests of the entries
+// that are dirty, i.e. newer than flushed.
+func linkIngestedEntries(span trace.Span, entries []StatusEntry, flushed uint64) {
+	var links int
+	for _, e := range entries {
+		if links == maxReconcileLinks {
+			return
+		}
+		if e.Generation <= flushed {
+			continue
+		}
+		link, ok := tracing.Link(e.TraceParent,
+			attribute.String("cluster", e.Cluster),
+			attribute.String("claim.ref", e.ClaimRef),
+		)
+		if ok {
+			span.AddLink(link)
+			links++
+		}
+	}
+}
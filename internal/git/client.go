@@ -0,0 +1,15 @@
+package git
+
+import (
+	"net/http"
+	"time"
+)
+
+// requestTimeout bounds a single request to a Git provider API, so a hung
+// provider cannot stall the reconciler or the readiness probe.
+const requestTimeout = 30 * time.Second
+
+// newHTTPClient returns the HTTP client of an API-based provider.
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
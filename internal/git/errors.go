@@ -0,0 +1,7 @@
+package git
+
+import "errors"
+
+// ErrNotFound is returned (wrapped) when the requested file, branch or ref
+// does not exist in the remote repository.
+var ErrNotFound = errors.New("not found")
@@ -0,0 +1,412 @@
+package git
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+)
+
+// giteaPageSize is the number of pull requests requested per page.
+const giteaPageSize = 50
+
+// GiteaClient interacts with the REST API of Gitea or Forgejo (1.22 or
+// later) to manage files, branches, and pull requests.
+//
+// Gitea cannot move a branch to an arbitrary commit through its API, and
+// deleting the branch would close its open pull request. CommitFile therefore
+// recreates the status branch from the base commit only while no pull request
+// is open for it, and otherwise rebases the pull request onto its base branch
+// before committing on top. If the rebase conflicts, the commit goes on top of
+// the old branch; changes merged into the base branch since then appear in the
+// pull request diff, but merge cleanly because the committed file already
+// contains them.
+type GiteaClient struct {
+	token      string
+	owner      string
+	repo       string
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewGiteaClient creates a GiteaClient for owner/repo on the instance at
+// instanceURL, e.g. https://gitea.example.com.
+func NewGiteaClient(token, instanceURL, owner, repo string) *GiteaClient {
+	return &GiteaClient{
+		token:      token,
+		owner:      owner,
+		repo:       repo,
+		httpClient: newHTTPClient(),
+		baseURL:    strings.TrimSuffix(instanceURL, "/") + "/api/v1",
+	}
+}
+
+// FetchFile retrieves a file's content and blob SHA from the given ref.
+func (c *GiteaClient) FetchFile(path, ref string) ([]byte, string, error) {
+	apiPath := fmt.Sprintf("/repos/%s/%s/contents/%s?ref=%s",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), path, url.QueryEscape(ref))
+
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", fmt.Errorf("fetch file %s: %w", path, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch file: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Content  string `json:"content"`
+		SHA      string `json:"sha"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("fetch file: decode response: %w", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(result.Content)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: decode base64: %w", err)
+	}
+
+	return decoded, result.SHA, nil
+}
+
+// CreateBranch creates a new branch pointing at the given commit SHA.
+func (c *GiteaClient) CreateBranch(baseSHA, branchName string) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/branches",
+		url.PathEscape(c.owner), url.PathEscape(c.repo))
+
+	body := map[string]string{
+		"new_branch_name": branchName,
+		"old_ref_name":    baseSHA,
+	}
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, body)
+	if err != nil {
+		return fmt.Errorf("create branch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("create branch: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CommitFile sets path to content on branchName, creating the branch from
+// baseSHA if it does not exist and otherwise rebuilding it on the base as
+// described for GiteaClient. Nothing is committed if the file on the branch
+// already has the given content.
+func (c *GiteaClient) CommitFile(baseSHA, branchName, path, message string, content []byte) error {
+	head, err := c.GetRef(branchName)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if err := c.CreateBranch(baseSHA, branchName); err != nil {
+			return fmt.Errorf("commit file: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("commit file: %w", err)
+	case head != baseSHA:
+		if err := c.rebaseBranch(baseSHA, branchName); err != nil {
+			return fmt.Errorf("commit file: %w", err)
+		}
+	}
+
+	current, fileSHA, err := c.FetchFile(path, branchName)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		fileSHA = ""
+	case err != nil:
+		return fmt.Errorf("commit file: %w", err)
+	case bytes.Equal(current, content):
+		return nil
+	}
+
+	if err := c.UpdateFile(path, branchName, message, content, fileSHA); err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+	return nil
+}
+
+// rebaseBranch moves branchName onto the base. Without an open pull request
+// the branch is recreated from baseSHA, otherwise the pull request is rebased
+// onto its base branch, which Gitea allows only through the pull request.
+func (c *GiteaClient) rebaseBranch(baseSHA, branchName string) error {
+	prs, err := c.ListOpenPRs(branchName)
+	if err != nil {
+		return err
+	}
+	if len(prs) == 0 {
+		if err := c.DeleteBranch(branchName); err != nil {
+			return err
+		}
+		return c.CreateBranch(baseSHA, branchName)
+	}
+	return c.UpdatePRBranch(prs[0])
+}
+
+// DeleteBranch deletes the given branch. A missing branch is not an error.
+func (c *GiteaClient) DeleteBranch(branchName string) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/branches/%s",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), branchName)
+
+	resp, err := c.doRequest(http.MethodDelete, apiPath, nil)
+	if err != nil {
+		return fmt.Errorf("delete branch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete branch: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// UpdatePRBranch rebases the head branch of an open pull request onto its
+// base branch. A rebase that conflicts leaves the branch unchanged and is not
+// an error.
+func (c *GiteaClient) UpdatePRBranch(number int) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/pulls/%d/update?style=rebase",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), number)
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, nil)
+	if err != nil {
+		return fmt.Errorf("update PR branch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("update PR branch: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// UpdateFile commits content to path on the given branch. sha is the blob
+// SHA of the file being replaced, or empty to create the file.
+func (c *GiteaClient) UpdateFile(path, branchName, message string, content []byte, sha string) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/contents/%s",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), path)
+
+	body := map[string]string{
+		"message": message,
+		"content": base64.StdEncoding.EncodeToString(content),
+		"branch":  branchName,
+	}
+	method, want := http.MethodPost, http.StatusCreated
+	if sha != "" {
+		body["sha"] = sha
+		method, want = http.MethodPut, http.StatusOK
+	}
+
+	resp, err := c.doRequest(method, apiPath, body)
+	if err != nil {
+		return fmt.Errorf("update file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		return fmt.Errorf("update file: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CreatePR opens a pull request and returns the PR number.
+func (c *GiteaClient) CreatePR(title, body, head, base string) (int, error) {
+	apiPath := fmt.Sprintf("/repos/%s/%s/pulls",
+		url.PathEscape(c.owner), url.PathEscape(c.repo))
+
+	reqBody := map[string]string{
+		"title": title,
+		"body":  body,
+		"head":  head,
+		"base":  base,
+	}
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, reqBody)
+	if err != nil {
+		return 0, fmt.Errorf("create PR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("create PR: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Number int `json:"number"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("create PR: decode response: %w", err)
+	}
+
+	return result.Number, nil
+}
+
+// UpdatePR replaces the title and body of an existing pull request.
+func (c *GiteaClient) UpdatePR(number int, title, body string) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/pulls/%d",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), number)
+
+	reqBody := map[string]string{
+		"title": title,
+		"body":  body,
+	}
+
+	resp, err := c.doRequest(http.MethodPatch, apiPath, reqBody)
+	if err != nil {
+		return fmt.Errorf("update PR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Gitea answers an edit with 201 Created.
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update PR: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ClosePR closes an open pull request without merging it.
+func (c *GiteaClient) ClosePR(number int) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/pulls/%d",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), number)
+
+	resp, err := c.doRequest(http.MethodPatch, apiPath, map[string]string{"state": "closed"})
+	if err != nil {
+		return fmt.Errorf("close PR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("close PR: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ListOpenPRs returns PR numbers for open PRs from the given head branch of
+// this repository. The API cannot filter by head branch, so all open PRs are
+// listed page by page.
+func (c *GiteaClient) ListOpenPRs(head string) ([]int, error) {
+	var numbers []int
+	for page := 1; ; page++ {
+		apiPath := fmt.Sprintf("/repos/%s/%s/pulls?state=open&limit=%d&page=%d",
+			url.PathEscape(c.owner), url.PathEscape(c.repo), giteaPageSize, page)
+
+		prs, err := c.listPRs(apiPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			if pr.Head.Ref == head && pr.Head.RepoID == pr.Base.RepoID {
+				numbers = append(numbers, pr.Number)
+			}
+		}
+		if len(prs) < giteaPageSize {
+			return numbers, nil
+		}
+	}
+}
+
+type giteaPR struct {
+	Number int `json:"number"`
+	Head   struct {
+		Ref    string `json:"ref"`
+		RepoID int64  `json:"repo_id"`
+	} `json:"head"`
+	Base struct {
+		RepoID int64 `json:"repo_id"`
+	} `json:"base"`
+}
+
+func (c *GiteaClient) listPRs(apiPath string) ([]giteaPR, error) {
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list open PRs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list open PRs: unexpected status %d", resp.StatusCode)
+	}
+
+	var prs []giteaPR
+	if err := json.NewDecoder(resp.Body).Decode(&prs); err != nil {
+		return nil, fmt.Errorf("list open PRs: decode response: %w", err)
+	}
+	return prs, nil
+}
+
+// GetRef returns the commit SHA that the given branch points to.
+func (c *GiteaClient) GetRef(branch string) (string, error) {
+	apiPath := fmt.Sprintf("/repos/%s/%s/branches/%s",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), branch)
+
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("get ref: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("get ref %s: %w", branch, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get ref: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("get ref: decode response: %w", err)
+	}
+
+	return result.Commit.ID, nil
+}
+
+func (c *GiteaClient) doRequest(method, path string, body any) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request body: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "token "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitRequest("gitea", method, 0)
+		return nil, err
+	}
+	metrics.ObserveGitRequest("gitea", method, resp.StatusCode)
+	return resp, nil
+}
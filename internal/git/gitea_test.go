@@ -0,0 +1,300 @@
+package git
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newTestGiteaClient(url, token string) *GiteaClient {
+	return NewGiteaClient(token, url, "test-owner", "test-repo")
+}
+
+func TestGiteaFetchFile(t *testing.T) {
+	want := []byte("hello world")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/test-owner/test-repo/contents/path/to/file.yaml" {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		if r.URL.Query().Get("ref") != "main" {
+			t.Fatalf("expected ref 'main', got %q", r.URL.Query().Get("ref"))
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"content":  base64.StdEncoding.EncodeToString(want),
+			"sha":      "abc123sha",
+			"encoding": "base64",
+		})
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	content, sha, err := client.FetchFile("path/to/file.yaml", "main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != string(want) || sha != "abc123sha" {
+		t.Fatalf("expected %q at abc123sha, got %q at %q", want, content, sha)
+	}
+}
+
+// newGiteaCommitFileServer fakes the endpoints used by CommitFile. head is the
+// commit of the status branch, or empty if the branch does not exist, openPR
+// whether a pull request is open for it and branchContent the registry file
+// on it; recorded requests are appended to calls.
+func newGiteaCommitFileServer(t *testing.T, head string, openPR bool, branchContent string, calls *[]string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/repos/test-owner/test-repo/branches/status", func(w http.ResponseWriter, r *http.Request) {
+		if head == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"commit": map[string]string{"id": head}})
+	})
+	mux.HandleFunc("DELETE /api/v1/repos/test-owner/test-repo/branches/status", func(w http.ResponseWriter, r *http.Request) {
+		*calls = append(*calls, "delete branch")
+		head = ""
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/v1/repos/test-owner/test-repo/branches", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["new_branch_name"] != "status" || body["old_ref_name"] != "basesha" {
+			t.Fatalf("unexpected branch creation: %+v", body)
+		}
+		*calls = append(*calls, "create branch")
+		head = "basesha"
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET /api/v1/repos/test-owner/test-repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		prs := []map[string]any{}
+		if openPR {
+			prs = append(prs, giteaTestPR(7, "status", 1))
+		}
+		json.NewEncoder(w).Encode(prs)
+	})
+	mux.HandleFunc("POST /api/v1/repos/test-owner/test-repo/pulls/7/update", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("style") != "rebase" {
+			t.Fatalf("expected a rebase, got style %q", r.URL.Query().Get("style"))
+		}
+		*calls = append(*calls, "rebase PR")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /api/v1/repos/test-owner/test-repo/contents/registry.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != "status" {
+			t.Fatalf("expected file to be read from the status branch, got ref %q", r.URL.Query().Get("ref"))
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"content": base64.StdEncoding.EncodeToString([]byte(branchContent)),
+			"sha":     "filesha",
+		})
+	})
+	mux.HandleFunc("PUT /api/v1/repos/test-owner/test-repo/contents/registry.yaml", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		content, _ := base64.StdEncoding.DecodeString(body["content"])
+		if body["branch"] != "status" || body["sha"] != "filesha" || string(content) != "new content" {
+			t.Fatalf("unexpected file update: %+v", body)
+		}
+		*calls = append(*calls, "update file")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGiteaCommitFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		head          string
+		openPR        bool
+		branchContent string
+		want          []string
+	}{
+		{"new branch", "", false, "base content", []string{"create branch", "update file"}},
+		{"branch at base", "basesha", true, "old content", []string{"update file"}},
+		{"stale branch", "oldcommit", false, "old content", []string{"delete branch", "create branch", "update file"}},
+		{"stale branch with PR", "oldcommit", true, "old content", []string{"rebase PR", "update file"}},
+		{"unchanged", "oldcommit", true, "new content", []string{"rebase PR"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []string
+			srv := newGiteaCommitFileServer(t, tc.head, tc.openPR, tc.branchContent, &calls)
+			defer srv.Close()
+
+			client := newTestGiteaClient(srv.URL, "test-token")
+			if err := client.CommitFile("basesha", "status", "registry.yaml", "msg", []byte("new content")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(calls) != fmt.Sprint(tc.want) {
+				t.Fatalf("expected calls %v, got %v", tc.want, calls)
+			}
+		})
+	}
+}
+
+func TestGiteaCreatePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/repos/test-owner/test-repo/pulls" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["head"] != "feature-branch" || body["base"] != "main" || body["title"] != "Update status" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int{"number": 42})
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	num, err := client.CreatePR("Update status", "Automated update", "feature-branch", "main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if num != 42 {
+		t.Fatalf("expected PR number 42, got %d", num)
+	}
+}
+
+func TestGiteaUpdatePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/repos/test-owner/test-repo/pulls/42" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int{"number": 42})
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	if err := client.UpdatePR(42, "New title", "New body"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGiteaClosePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/repos/test-owner/test-repo/pulls/42" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["state"] != "closed" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"number": 42, "state": "closed"})
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	if err := client.ClosePR(42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGiteaListOpenPRs(t *testing.T) {
+	// The first page is full, so the client has to request the second one.
+	pages := map[string][]map[string]any{"1": {}, "2": {}}
+	for i := 1; i <= giteaPageSize; i++ {
+		pages["1"] = append(pages["1"], giteaTestPR(i, "other-branch", 1))
+	}
+	pages["2"] = []map[string]any{
+		giteaTestPR(100, "machinery/status-updates", 1),
+		giteaTestPR(101, "machinery/status-updates", 2), // from a fork
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != "open" || q.Get("limit") != strconv.Itoa(giteaPageSize) {
+			t.Fatalf("unexpected query %q", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(pages[q.Get("page")])
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	numbers, err := client.ListOpenPRs("machinery/status-updates")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(numbers) != 1 || numbers[0] != 100 {
+		t.Fatalf("expected PR [100], got %v", numbers)
+	}
+}
+
+func giteaTestPR(number int, head string, headRepoID int64) map[string]any {
+	return map[string]any{
+		"number": number,
+		"head":   map[string]any{"ref": head, "repo_id": headRepoID},
+		"base":   map[string]any{"ref": "main", "repo_id": 1},
+	}
+}
+
+func TestGiteaGetRef(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/test-owner/test-repo/branches/main" {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":   "main",
+			"commit": map[string]string{"id": "commitsha123456"},
+		})
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	sha, err := client.GetRef("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sha != "commitsha123456" {
+		t.Fatalf("expected sha 'commitsha123456', got %q", sha)
+	}
+}
+
+func TestGiteaGetRef_NotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL, "test-token")
+	if _, err := client.GetRef("nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGiteaAuthHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token my-secret-token" {
+			t.Fatalf("expected Authorization 'token my-secret-token', got %q", got)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"commit": map[string]string{"id": "sha1"}})
+	}))
+	defer srv.Close()
+
+	client := newTestGiteaClient(srv.URL+"/", "my-secret-token")
+	if _, err := client.GetRef("main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
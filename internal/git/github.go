@@ -2,12 +2,17 @@ package git
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
 )
 
 // GitHubClient interacts with the GitHub REST API to manage files, branches, and pull requests.
@@ -17,17 +22,78 @@ type GitHubClient struct {
 	repo       string
 	httpClient *http.Client
 	baseURL    string
+	// app, if set, replaces token with GitHub App installation tokens.
+	app *githubApp
+}
+
+// DefaultGitHubURL is the API of github.com, used unless overridden with
+// WithGitHubURL.
+const DefaultGitHubURL = "https://api.github.com"
+
+// GitHubOption configures a GitHubClient.
+type GitHubOption func(*GitHubClient)
+
+// WithGitHubURL targets the API at apiURL instead of github.com. For GitHub
+// Enterprise Server the instance URL, e.g. https://github.example.com, is
+// enough: the /api/v3 path is appended unless apiURL already has a path or
+// is an api. host.
+func WithGitHubURL(apiURL string) GitHubOption {
+	return func(c *GitHubClient) {
+		c.baseURL = githubAPIURL(apiURL)
+	}
+}
+
+// WithGitHubTLSConfig uses cfg for HTTPS connections to the API, e.g. to
+// trust the private CA of a GitHub Enterprise Server.
+func WithGitHubTLSConfig(cfg *tls.Config) GitHubOption {
+	return func(c *GitHubClient) {
+		c.transport().TLSClientConfig = cfg
+	}
+}
+
+// WithGitHubProxy sends API requests through the HTTP proxy at proxyURL
+// instead of the one configured by HTTPS_PROXY and NO_PROXY.
+func WithGitHubProxy(proxyURL *url.URL) GitHubOption {
+	return func(c *GitHubClient) {
+		c.transport().Proxy = http.ProxyURL(proxyURL)
+	}
 }
 
 // NewGitHubClient creates a GitHubClient configured for the given repository.
-func NewGitHubClient(token, owner, repo string) *GitHubClient {
-	return &GitHubClient{
+func NewGitHubClient(token, owner, repo string, opts ...GitHubOption) *GitHubClient {
+	c := &GitHubClient{
 		token:      token,
 		owner:      owner,
 		repo:       repo,
-		httpClient: &http.Client{},
-		baseURL:    "https://api.github.com",
+		httpClient: newHTTPClient(),
+		baseURL:    DefaultGitHubURL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// transport returns the client's own transport, cloning the default one on
+// first use so options do not affect other clients.
+func (c *GitHubClient) transport() *http.Transport {
+	t, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+		c.httpClient.Transport = t
+	}
+	return t
+}
+
+// githubAPIURL returns the REST API root for a github.com or GitHub
+// Enterprise URL.
+func githubAPIURL(rawURL string) string {
+	rawURL = strings.TrimSuffix(rawURL, "/")
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path != "" || strings.HasPrefix(u.Host, "api.") {
+		return rawURL
 	}
+	return rawURL + "/api/v3"
 }
 
 // FetchFile retrieves a file's content and SHA from the given ref.
@@ -85,6 +151,150 @@ func (c *GitHubClient) CreateBranch(baseSHA, branchName string) error {
 	return nil
 }
 
+// UpdateBranch force-moves an existing branch to point at the given SHA.
+func (c *GitHubClient) UpdateBranch(sha, branchName string) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/git/refs/heads/%s",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), branchName)
+
+	body := map[string]any{
+		"sha":   sha,
+		"force": true,
+	}
+
+	resp, err := c.doRequest(http.MethodPatch, apiPath, body)
+	if err != nil {
+		return fmt.Errorf("update branch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update branch: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CommitFile creates a commit on top of baseSHA that sets path to content and
+// force-moves branchName to it, creating the branch if it does not exist. The
+// branch is moved in a single ref update, so it never points at baseSHA itself
+// and an open PR for it is not closed for lack of changes.
+func (c *GitHubClient) CommitFile(baseSHA, branchName, path, message string, content []byte) error {
+	baseTree, err := c.getCommitTree(baseSHA)
+	if err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+
+	tree, err := c.createTree(baseTree, path, content)
+	if err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+
+	commitSHA, err := c.createCommit(message, tree, baseSHA)
+	if err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+
+	_, err = c.GetRef(branchName)
+	switch {
+	case err == nil:
+		return c.UpdateBranch(commitSHA, branchName)
+	case errors.Is(err, ErrNotFound):
+		return c.CreateBranch(commitSHA, branchName)
+	default:
+		return fmt.Errorf("commit file: %w", err)
+	}
+}
+
+func (c *GitHubClient) getCommitTree(sha string) (string, error) {
+	apiPath := fmt.Sprintf("/repos/%s/%s/git/commits/%s",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), sha)
+
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("get commit: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get commit: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Tree struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("get commit: decode response: %w", err)
+	}
+
+	return result.Tree.SHA, nil
+}
+
+func (c *GitHubClient) createTree(baseTree, path string, content []byte) (string, error) {
+	apiPath := fmt.Sprintf("/repos/%s/%s/git/trees",
+		url.PathEscape(c.owner), url.PathEscape(c.repo))
+
+	body := map[string]any{
+		"base_tree": baseTree,
+		"tree": []map[string]string{{
+			"path":    path,
+			"mode":    "100644",
+			"type":    "blob",
+			"content": string(content),
+		}},
+	}
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, body)
+	if err != nil {
+		return "", fmt.Errorf("create tree: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("create tree: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("create tree: decode response: %w", err)
+	}
+
+	return result.SHA, nil
+}
+
+func (c *GitHubClient) createCommit(message, tree, parent string) (string, error) {
+	apiPath := fmt.Sprintf("/repos/%s/%s/git/commits",
+		url.PathEscape(c.owner), url.PathEscape(c.repo))
+
+	body := map[string]any{
+		"message": message,
+		"tree":    tree,
+		"parents": []string{parent},
+	}
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, body)
+	if err != nil {
+		return "", fmt.Errorf("create commit: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("create commit: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("create commit: decode response: %w", err)
+	}
+
+	return result.SHA, nil
+}
+
 // UpdateFile commits an update to a file on the given branch.
 func (c *GitHubClient) UpdateFile(path, branchName, message string, content []byte, sha string) error {
 	apiPath := fmt.Sprintf("/repos/%s/%s/contents/%s",
@@ -142,6 +352,47 @@ func (c *GitHubClient) CreatePR(title, body, head, base string) (int, error) {
 	return result.Number, nil
 }
 
+// UpdatePR replaces the title and body of an existing pull request.
+func (c *GitHubClient) UpdatePR(number int, title, body string) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/pulls/%d",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), number)
+
+	reqBody := map[string]string{
+		"title": title,
+		"body":  body,
+	}
+
+	resp, err := c.doRequest(http.MethodPatch, apiPath, reqBody)
+	if err != nil {
+		return fmt.Errorf("update PR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update PR: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ClosePR closes an open pull request without merging it.
+func (c *GitHubClient) ClosePR(number int) error {
+	apiPath := fmt.Sprintf("/repos/%s/%s/pulls/%d",
+		url.PathEscape(c.owner), url.PathEscape(c.repo), number)
+
+	resp, err := c.doRequest(http.MethodPatch, apiPath, map[string]string{"state": "closed"})
+	if err != nil {
+		return fmt.Errorf("close PR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("close PR: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
 // ListOpenPRs returns PR numbers for open PRs from the given head branch.
 func (c *GitHubClient) ListOpenPRs(head string) ([]int, error) {
 	apiPath := fmt.Sprintf("/repos/%s/%s/pulls?state=open&head=%s:%s",
@@ -183,6 +434,9 @@ func (c *GitHubClient) GetRef(branch string) (string, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("get ref %s: %w", branch, ErrNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("get ref: unexpected status %d", resp.StatusCode)
 	}
@@ -214,9 +468,22 @@ func (c *GitHubClient) doRequest(method, path string, body any) (*http.Response,
 		return nil, fmt.Errorf("build request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	token, err := c.authToken()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/vnd.github+json")
 
-	return c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitRequest("github", method, 0)
+		return nil, err
+	}
+	metrics.ObserveGitRequest("github", method, resp.StatusCode)
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.invalidateToken()
+	}
+	return resp, nil
 }
@@ -1,10 +1,14 @@
 package git
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -104,6 +108,133 @@ func TestCreateBranch_AlreadyExists(t *testing.T) {
 	}
 }
 
+func TestUpdateBranch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			t.Fatalf("expected PATCH, got %s", r.Method)
+		}
+		if r.URL.Path != "/repos/test-owner/test-repo/git/refs/heads/machinery/status-updates" {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["sha"] != "deadbeef" {
+			t.Fatalf("expected sha 'deadbeef', got %v", body["sha"])
+		}
+		if body["force"] != true {
+			t.Fatalf("expected force true, got %v", body["force"])
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"ref": "refs/heads/machinery/status-updates"})
+	}))
+	defer srv.Close()
+
+	client := newTestClient(srv.URL, "test-token")
+	if err := client.UpdateBranch("deadbeef", "machinery/status-updates"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// newCommitFileServer fakes the Git Data API endpoints used by CommitFile.
+// refExists controls whether the target branch is already present; the final
+// ref update is recorded in refMethod and refSHA.
+func newCommitFileServer(t *testing.T, refExists bool, refMethod, refSHA *string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/test-owner/test-repo/git/commits/basesha", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"tree": map[string]string{"sha": "basetree"}})
+	})
+	mux.HandleFunc("POST /repos/test-owner/test-repo/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			BaseTree string              `json:"base_tree"`
+			Tree     []map[string]string `json:"tree"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body.BaseTree != "basetree" {
+			t.Fatalf("expected base_tree 'basetree', got %q", body.BaseTree)
+		}
+		if len(body.Tree) != 1 || body.Tree[0]["path"] != "registry.yaml" || body.Tree[0]["content"] != "new content" {
+			t.Fatalf("unexpected tree: %+v", body.Tree)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"sha": "newtree"})
+	})
+	mux.HandleFunc("POST /repos/test-owner/test-repo/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Message string   `json:"message"`
+			Tree    string   `json:"tree"`
+			Parents []string `json:"parents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body.Tree != "newtree" || len(body.Parents) != 1 || body.Parents[0] != "basesha" {
+			t.Fatalf("unexpected commit: %+v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"sha": "newcommit"})
+	})
+	mux.HandleFunc("GET /repos/test-owner/test-repo/git/ref/heads/status", func(w http.ResponseWriter, r *http.Request) {
+		if !refExists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"object": map[string]string{"sha": "oldcommit"}})
+	})
+	mux.HandleFunc("PATCH /repos/test-owner/test-repo/git/refs/heads/status", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		*refMethod, *refSHA = r.Method, body["sha"].(string)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("POST /repos/test-owner/test-repo/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["ref"] != "refs/heads/status" {
+			t.Fatalf("unexpected ref %q", body["ref"])
+		}
+		*refMethod, *refSHA = r.Method, body["sha"]
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestCommitFile_NewBranch(t *testing.T) {
+	var method, sha string
+	srv := newCommitFileServer(t, false, &method, &sha)
+	defer srv.Close()
+
+	client := newTestClient(srv.URL, "test-token")
+	if err := client.CommitFile("basesha", "status", "registry.yaml", "msg", []byte("new content")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != http.MethodPost || sha != "newcommit" {
+		t.Fatalf("expected branch to be created at newcommit, got %s %q", method, sha)
+	}
+}
+
+func TestCommitFile_ExistingBranch(t *testing.T) {
+	var method, sha string
+	srv := newCommitFileServer(t, true, &method, &sha)
+	defer srv.Close()
+
+	client := newTestClient(srv.URL, "test-token")
+	if err := client.CommitFile("basesha", "status", "registry.yaml", "msg", []byte("new content")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != http.MethodPatch || sha != "newcommit" {
+		t.Fatalf("expected branch to be force-updated to newcommit, got %s %q", method, sha)
+	}
+}
+
 func TestUpdateFile(t *testing.T) {
 	fileContent := []byte("updated content")
 	fileSHA := "oldsha123"
@@ -182,6 +313,59 @@ func TestCreatePR(t *testing.T) {
 	}
 }
 
+func TestUpdatePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			t.Fatalf("expected PATCH, got %s", r.Method)
+		}
+		if r.URL.Path != "/repos/test-owner/test-repo/pulls/42" {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["title"] != "Update status" || body["body"] != "New body" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"number": 42})
+	}))
+	defer srv.Close()
+
+	client := newTestClient(srv.URL, "test-token")
+	if err := client.UpdatePR(42, "Update status", "New body"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClosePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/repos/test-owner/test-repo/pulls/42" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["state"] != "closed" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"number": 42, "state": "closed"})
+	}))
+	defer srv.Close()
+
+	client := newTestClient(srv.URL, "test-token")
+	if err := client.ClosePR(42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestListOpenPRs(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -271,6 +455,9 @@ func TestGetRef_NotFound(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for 404 response")
 	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
 }
 
 func TestAuthHeader(t *testing.T) {
@@ -300,3 +487,74 @@ func TestAuthHeader(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestGitHubAPIURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://api.github.com", "https://api.github.com"},
+		{"https://github.example.com", "https://github.example.com/api/v3"},
+		{"https://github.example.com/", "https://github.example.com/api/v3"},
+		{"https://github.example.com/api/v3", "https://github.example.com/api/v3"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/api/v3"},
+		{"https://api.acme.ghe.com", "https://api.acme.ghe.com"},
+	}
+	for _, tt := range tests {
+		if got := githubAPIURL(tt.in); got != tt.want {
+			t.Errorf("githubAPIURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWithGitHubURLAndTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/test-owner/test-repo/git/ref/heads/main" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"object": map[string]string{"sha": "abc"}})
+	}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	client := NewGitHubClient("token", "test-owner", "test-repo",
+		WithGitHubURL(srv.URL),
+		WithGitHubTLSConfig(&tls.Config{RootCAs: pool}),
+	)
+
+	sha, err := client.GetRef("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sha != "abc" {
+		t.Fatalf("expected sha abc, got %q", sha)
+	}
+}
+
+func TestWithGitHubProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy receives the absolute target URL.
+		proxied = r.URL.String()
+		json.NewEncoder(w).Encode(map[string]any{"object": map[string]string{"sha": "abc"}})
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf("parse proxy URL: %v", err)
+	}
+	client := NewGitHubClient("token", "test-owner", "test-repo",
+		WithGitHubURL("http://github.example.com"),
+		WithGitHubProxy(proxyURL),
+	)
+
+	if _, err := client.GetRef("main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "http://github.example.com/api/v3/repos/test-owner/test-repo/git/ref/heads/main"; proxied != want {
+		t.Fatalf("expected proxied request to %q, got %q", want, proxied)
+	}
+}
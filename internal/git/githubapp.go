@@ -0,0 +1,184 @@
+package git
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+)
+
+const (
+	// appJWTLifetime is the validity of the JWT authenticating the app. GitHub
+	// accepts at most ten minutes.
+	appJWTLifetime = 9 * time.Minute
+	// appClockSkew backdates the JWT to tolerate clock drift.
+	appClockSkew = time.Minute
+	// appTokenRefresh is how long before expiry an installation token is
+	// replaced. Tokens are valid for one hour.
+	appTokenRefresh = 5 * time.Minute
+)
+
+// githubApp authenticates as a GitHub App installation. It exchanges a JWT
+// signed with the app's private key for an installation token and caches the
+// token until shortly before it expires.
+type githubApp struct {
+	id             string
+	installationID int64
+	key            *rsa.PrivateKey
+	now            func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewGitHubAppClient creates a GitHubClient that authenticates as the given
+// installation of a GitHub App, so commits and pull requests are authored by
+// the app's bot user. appID is the App ID or client ID and privateKey the
+// PEM-encoded private key generated for the app.
+func NewGitHubAppClient(appID string, installationID int64, privateKey []byte, owner, repo string, opts ...GitHubOption) (*GitHubClient, error) {
+	key, err := parseRSAPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse GitHub App private key: %w", err)
+	}
+
+	c := NewGitHubClient("", owner, repo, opts...)
+	c.app = &githubApp{
+		id:             appID,
+		installationID: installationID,
+		key:            key,
+		now:            time.Now,
+	}
+	return c, nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T, expected RSA", key)
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// authToken returns the token for the Authorization header: the static
+// token, or a cached or freshly minted installation token.
+func (c *GitHubClient) authToken() (string, error) {
+	if c.app == nil {
+		return c.token, nil
+	}
+
+	c.app.mu.Lock()
+	defer c.app.mu.Unlock()
+	if c.app.token != "" && c.app.now().Before(c.app.expires.Add(-appTokenRefresh)) {
+		return c.app.token, nil
+	}
+
+	token, expires, err := c.createInstallationToken()
+	if err != nil {
+		return "", fmt.Errorf("create installation token: %w", err)
+	}
+	c.app.token, c.app.expires = token, expires
+	return token, nil
+}
+
+// invalidateToken drops a cached installation token the API rejected, so the
+// next request mints a new one.
+func (c *GitHubClient) invalidateToken() {
+	if c.app == nil {
+		return
+	}
+	c.app.mu.Lock()
+	defer c.app.mu.Unlock()
+	c.app.token = ""
+}
+
+// createInstallationToken exchanges an app JWT for an installation token.
+func (c *GitHubClient) createInstallationToken() (string, time.Time, error) {
+	jwt, err := c.app.jwt()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	apiPath := fmt.Sprintf("/app/installations/%d/access_tokens", c.app.installationID)
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+apiPath, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitRequest("github", http.MethodPost, 0)
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	metrics.ObserveGitRequest("github", http.MethodPost, resp.StatusCode)
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", time.Time{}, fmt.Errorf("decode response: %w", err)
+	}
+
+	return result.Token, result.ExpiresAt, nil
+}
+
+// jwt returns a JSON Web Token signed with RS256 that authenticates the app
+// itself.
+func (a *githubApp) jwt() (string, error) {
+	now := a.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": a.id,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
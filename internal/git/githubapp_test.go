@@ -0,0 +1,205 @@
+package git
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func generateAppKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// verifyAppJWT checks the RS256 signature and claims of a JWT and returns its
+// issuer.
+func verifyAppJWT(t *testing.T, key *rsa.PrivateKey, jwt string) string {
+	t.Helper()
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 JWT segments, got %d", len(parts))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("invalid JWT signature: %v", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	var claims struct {
+		IAT int64  `json:"iat"`
+		EXP int64  `json:"exp"`
+		ISS string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("unmarshal claims: %v", err)
+	}
+	if lifetime := claims.EXP - claims.IAT; lifetime <= 0 || lifetime > 600 {
+		t.Fatalf("expected a JWT lifetime of at most 10 minutes, got %ds", lifetime)
+	}
+	return claims.ISS
+}
+
+// newGitHubAppServer fakes the installation token endpoint and a ref lookup.
+// Minted tokens are numbered and valid for an hour from now().
+func newGitHubAppServer(t *testing.T, key *rsa.PrivateKey, now func() time.Time, minted *int) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if iss := verifyAppJWT(t, key, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")); iss != "123" {
+			t.Errorf("expected issuer 123, got %q", iss)
+		}
+		*minted++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"token":      fmt.Sprintf("ghs_%d", *minted),
+			"expires_at": now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	})
+	mux.HandleFunc("GET /repos/test-owner/test-repo/git/ref/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		if want := fmt.Sprintf("Bearer ghs_%d", *minted); r.Header.Get("Authorization") != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"object": map[string]string{"sha": "abc"}})
+	})
+	return httptest.NewServer(mux)
+}
+
+func newTestAppClient(t *testing.T, url string, pemKey []byte, now func() time.Time) *GitHubClient {
+	t.Helper()
+
+	client, err := NewGitHubAppClient("123", 42, pemKey, "test-owner", "test-repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.baseURL = url
+	client.app.now = now
+	return client
+}
+
+func TestGitHubApp_CachesAndRefreshesToken(t *testing.T) {
+	key, pemKey := generateAppKey(t)
+	clock := time.Now()
+	now := func() time.Time { return clock }
+	var minted int
+	srv := newGitHubAppServer(t, key, now, &minted)
+	defer srv.Close()
+
+	client := newTestAppClient(t, srv.URL, pemKey, now)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetRef("main"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if minted != 1 {
+		t.Fatalf("expected the token to be cached, minted %d", minted)
+	}
+
+	// Shortly before expiry the token is replaced.
+	clock = clock.Add(56 * time.Minute)
+	if _, err := client.GetRef("main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if minted != 2 {
+		t.Fatalf("expected the token to be refreshed, minted %d", minted)
+	}
+}
+
+func TestGitHubApp_RejectedTokenIsReplaced(t *testing.T) {
+	key, pemKey := generateAppKey(t)
+	now := time.Now
+	var minted int
+	srv := newGitHubAppServer(t, key, now, &minted)
+	defer srv.Close()
+
+	client := newTestAppClient(t, srv.URL, pemKey, now)
+	client.app.token, client.app.expires = "revoked", now().Add(time.Hour)
+
+	if _, err := client.GetRef("main"); err == nil {
+		t.Fatal("expected the revoked token to be rejected")
+	}
+	if _, err := client.GetRef("main"); err != nil {
+		t.Fatalf("expected a new token after rejection, got %v", err)
+	}
+	if minted != 1 {
+		t.Fatalf("expected one token to be minted, got %d", minted)
+	}
+}
+
+func TestGitHubApp_TokenError(t *testing.T) {
+	_, pemKey := generateAppKey(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := newTestAppClient(t, srv.URL, pemKey, time.Now)
+
+	_, err := client.GetRef("main")
+	if err == nil || !strings.Contains(err.Error(), "create installation token") {
+		t.Fatalf("expected installation token error, got %v", err)
+	}
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, pkcs1 := generateAppKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"pkcs1", pkcs1, false},
+		{"pkcs8", pkcs8, false},
+		{"not pem", []byte("not a key"), true},
+		{"wrong block", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRSAPrivateKey(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(key) {
+				t.Fatal("parsed key differs")
+			}
+		})
+	}
+}
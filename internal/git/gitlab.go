@@ -0,0 +1,267 @@
+package git
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+)
+
+// DefaultGitLabURL is the GitLab instance used unless overridden.
+const DefaultGitLabURL = "https://gitlab.com"
+
+// GitLabClient interacts with the GitLab REST API (v4) to manage files,
+// branches, and merge requests. Merge requests are identified by their
+// project-scoped IID, which plays the role of a GitHub PR number.
+type GitLabClient struct {
+	token      string
+	project    string
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewGitLabClient creates a GitLabClient for the project owner/repo on the
+// GitLab instance at instanceURL, e.g. https://gitlab.example.com. owner may
+// be a nested group path such as "platform/registries".
+func NewGitLabClient(token, instanceURL, owner, repo string) *GitLabClient {
+	return &GitLabClient{
+		token:      token,
+		project:    owner + "/" + repo,
+		httpClient: newHTTPClient(),
+		baseURL:    strings.TrimSuffix(instanceURL, "/") + "/api/v4",
+	}
+}
+
+// projectPath returns the API path prefix of the project, addressed by its
+// URL-encoded full path.
+func (c *GitLabClient) projectPath() string {
+	return "/projects/" + url.PathEscape(c.project)
+}
+
+// FetchFile retrieves a file's content and blob SHA from the given ref.
+func (c *GitLabClient) FetchFile(path, ref string) ([]byte, string, error) {
+	apiPath := fmt.Sprintf("%s/repository/files/%s?ref=%s",
+		c.projectPath(), url.PathEscape(path), url.QueryEscape(ref))
+
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", fmt.Errorf("fetch file %s: %w", path, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch file: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Content  string `json:"content"`
+		BlobID   string `json:"blob_id"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("fetch file: decode response: %w", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(result.Content)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: decode base64: %w", err)
+	}
+
+	return decoded, result.BlobID, nil
+}
+
+// CommitFile creates a commit on top of baseSHA that sets path to content and
+// force-moves branchName to it, creating the branch if it does not exist.
+// GitLab does this in a single request to the commits API.
+func (c *GitLabClient) CommitFile(baseSHA, branchName, path, message string, content []byte) error {
+	apiPath := c.projectPath() + "/repository/commits"
+
+	body := map[string]any{
+		"branch":         branchName,
+		"commit_message": message,
+		"start_sha":      baseSHA,
+		"force":          true,
+		"actions": []map[string]string{{
+			"action":    "update",
+			"file_path": path,
+			"content":   string(content),
+		}},
+	}
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, body)
+	if err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("commit file: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CreatePR opens a merge request and returns its IID.
+func (c *GitLabClient) CreatePR(title, body, head, base string) (int, error) {
+	apiPath := c.projectPath() + "/merge_requests"
+
+	reqBody := map[string]string{
+		"title":         title,
+		"description":   body,
+		"source_branch": head,
+		"target_branch": base,
+	}
+
+	resp, err := c.doRequest(http.MethodPost, apiPath, reqBody)
+	if err != nil {
+		return 0, fmt.Errorf("create MR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("create MR: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		IID int `json:"iid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("create MR: decode response: %w", err)
+	}
+
+	return result.IID, nil
+}
+
+// UpdatePR replaces the title and description of an existing merge request.
+func (c *GitLabClient) UpdatePR(number int, title, body string) error {
+	apiPath := fmt.Sprintf("%s/merge_requests/%d", c.projectPath(), number)
+
+	reqBody := map[string]string{
+		"title":       title,
+		"description": body,
+	}
+
+	resp, err := c.doRequest(http.MethodPut, apiPath, reqBody)
+	if err != nil {
+		return fmt.Errorf("update MR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update MR: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ClosePR closes an open merge request without merging it.
+func (c *GitLabClient) ClosePR(number int) error {
+	apiPath := fmt.Sprintf("%s/merge_requests/%d", c.projectPath(), number)
+
+	resp, err := c.doRequest(http.MethodPut, apiPath, map[string]string{"state_event": "close"})
+	if err != nil {
+		return fmt.Errorf("close MR: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("close MR: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ListOpenPRs returns the IIDs of open merge requests from the given source
+// branch.
+func (c *GitLabClient) ListOpenPRs(head string) ([]int, error) {
+	apiPath := fmt.Sprintf("%s/merge_requests?state=opened&source_branch=%s",
+		c.projectPath(), url.QueryEscape(head))
+
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list open MRs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list open MRs: unexpected status %d", resp.StatusCode)
+	}
+
+	var mrs []struct {
+		IID int `json:"iid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&mrs); err != nil {
+		return nil, fmt.Errorf("list open MRs: decode response: %w", err)
+	}
+
+	numbers := make([]int, len(mrs))
+	for i, mr := range mrs {
+		numbers[i] = mr.IID
+	}
+	return numbers, nil
+}
+
+// GetRef returns the commit SHA that the given branch points to.
+func (c *GitLabClient) GetRef(branch string) (string, error) {
+	apiPath := fmt.Sprintf("%s/repository/branches/%s", c.projectPath(), url.PathEscape(branch))
+
+	resp, err := c.doRequest(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("get ref: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("get ref %s: %w", branch, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get ref: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Commit struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("get ref: decode response: %w", err)
+	}
+
+	return result.Commit.ID, nil
+}
+
+func (c *GitLabClient) doRequest(method, path string, body any) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request body: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitRequest("gitlab", method, 0)
+		return nil, err
+	}
+	metrics.ObserveGitRequest("gitlab", method, resp.StatusCode)
+	return resp, nil
+}
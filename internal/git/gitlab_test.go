@@ -0,0 +1,281 @@
+package git
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testGitLabProject = "/api/v4/projects/test-group%2Ftest-repo"
+
+func newTestGitLabClient(url, token string) *GitLabClient {
+	return NewGitLabClient(token, url, "test-group", "test-repo")
+}
+
+func TestGitLabFetchFile(t *testing.T) {
+	want := []byte("hello world")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Fatalf("expected GET, got %s", r.Method)
+		}
+		if r.URL.EscapedPath() != testGitLabProject+"/repository/files/path%2Fto%2Ffile.yaml" {
+			t.Fatalf("unexpected path %q", r.URL.EscapedPath())
+		}
+		if r.URL.Query().Get("ref") != "main" {
+			t.Fatalf("expected ref 'main', got %q", r.URL.Query().Get("ref"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"content":  base64.StdEncoding.EncodeToString(want),
+			"blob_id":  "blob123",
+			"encoding": "base64",
+		})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	content, sha, err := client.FetchFile("path/to/file.yaml", "main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != string(want) {
+		t.Fatalf("expected content %q, got %q", want, content)
+	}
+	if sha != "blob123" {
+		t.Fatalf("expected sha 'blob123', got %q", sha)
+	}
+}
+
+func TestGitLabFetchFile_NotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "404 File Not Found"})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	_, _, err := client.FetchFile("nonexistent.yaml", "main")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGitLabCommitFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("expected POST, got %s", r.Method)
+		}
+		if r.URL.EscapedPath() != testGitLabProject+"/repository/commits" {
+			t.Fatalf("unexpected path %q", r.URL.EscapedPath())
+		}
+
+		var body struct {
+			Branch        string              `json:"branch"`
+			CommitMessage string              `json:"commit_message"`
+			StartSHA      string              `json:"start_sha"`
+			Force         bool                `json:"force"`
+			Actions       []map[string]string `json:"actions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body.Branch != "status" || body.StartSHA != "basesha" || !body.Force {
+			t.Fatalf("expected forced commit on 'status' from 'basesha', got %+v", body)
+		}
+		if len(body.Actions) != 1 || body.Actions[0]["action"] != "update" ||
+			body.Actions[0]["file_path"] != "registry.yaml" || body.Actions[0]["content"] != "new content" {
+			t.Fatalf("unexpected actions: %+v", body.Actions)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": "newcommit"})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	if err := client.CommitFile("basesha", "status", "registry.yaml", "msg", []byte("new content")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGitLabCommitFile_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "A file with this name doesn't exist"})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	if err := client.CommitFile("basesha", "status", "registry.yaml", "msg", []byte("x")); err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+}
+
+func TestGitLabCreatePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("expected POST, got %s", r.Method)
+		}
+		if r.URL.EscapedPath() != testGitLabProject+"/merge_requests" {
+			t.Fatalf("unexpected path %q", r.URL.EscapedPath())
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["title"] != "Update status" || body["description"] != "Automated update" {
+			t.Fatalf("unexpected title/description: %+v", body)
+		}
+		if body["source_branch"] != "feature-branch" || body["target_branch"] != "main" {
+			t.Fatalf("unexpected branches: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]int{"id": 9001, "iid": 42})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	iid, err := client.CreatePR("Update status", "Automated update", "feature-branch", "main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if iid != 42 {
+		t.Fatalf("expected MR IID 42, got %d", iid)
+	}
+}
+
+func TestGitLabUpdatePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Fatalf("expected PUT, got %s", r.Method)
+		}
+		if r.URL.EscapedPath() != testGitLabProject+"/merge_requests/42" {
+			t.Fatalf("unexpected path %q", r.URL.EscapedPath())
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["title"] != "New title" || body["description"] != "New body" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int{"iid": 42})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	if err := client.UpdatePR(42, "New title", "New body"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGitLabClosePR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.EscapedPath() != testGitLabProject+"/merge_requests/42" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode request body: %v", err)
+		}
+		if body["state_event"] != "close" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"iid": 42, "state": "closed"})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	if err := client.ClosePR(42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGitLabListOpenPRs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Fatalf("expected GET, got %s", r.Method)
+		}
+		q := r.URL.Query()
+		if q.Get("state") != "opened" || q.Get("source_branch") != "machinery/status-updates" {
+			t.Fatalf("unexpected query %q", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]map[string]int{{"iid": 1}, {"iid": 2}})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	iids, err := client.ListOpenPRs("machinery/status-updates")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(iids) != 2 || iids[0] != 1 || iids[1] != 2 {
+		t.Fatalf("expected IIDs [1 2], got %v", iids)
+	}
+}
+
+func TestGitLabGetRef(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != testGitLabProject+"/repository/branches/machinery%2Fstatus-updates" {
+			t.Fatalf("unexpected path %q", r.URL.EscapedPath())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"name":   "machinery/status-updates",
+			"commit": map[string]string{"id": "commitsha123456"},
+		})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	sha, err := client.GetRef("machinery/status-updates")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sha != "commitsha123456" {
+		t.Fatalf("expected sha 'commitsha123456', got %q", sha)
+	}
+}
+
+func TestGitLabGetRef_NotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "404 Branch Not Found"})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL, "test-token")
+	_, err := client.GetRef("nonexistent")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGitLabAuthHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "my-secret-token" {
+			t.Fatalf("expected PRIVATE-TOKEN 'my-secret-token', got %q", got)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"commit": map[string]string{"id": "sha1"}})
+	}))
+	defer srv.Close()
+
+	client := newTestGitLabClient(srv.URL+"/", "my-secret-token")
+	if _, err := client.GetRef("main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,420 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// Default identity of the commits created by PlainGitClient.
+const (
+	DefaultAuthorName  = "machinery-status-collector"
+	DefaultAuthorEmail = "machinery-status-collector@localhost"
+)
+
+const remoteName = "origin"
+
+// PlainGitClient talks the git protocol to any remote, such as a plain SSH
+// git server or a local bare repository. It keeps a bare clone of the
+// remote in a work directory, builds commits in it and pushes them.
+//
+// Plain git has no pull requests: CommitFile pushes the status branch, which
+// is proposed by the branch itself, and CreatePR only reports that. With
+// WithDirectPush the commits go straight to the base branch instead.
+type PlainGitClient struct {
+	url        string
+	workDir    string
+	auth       transport.AuthMethod
+	pushBranch string
+
+	authorName  string
+	authorEmail string
+
+	sshKeyFile     string
+	sshKeyPassword string
+
+	// mu serialises access to the work directory.
+	mu   sync.Mutex
+	repo *gogit.Repository
+}
+
+// PlainGitOption configures a PlainGitClient.
+type PlainGitOption func(*PlainGitClient)
+
+// WithBasicAuth authenticates HTTP(S) remotes with username and password,
+// which may also be an access token.
+func WithBasicAuth(username, password string) PlainGitOption {
+	return func(c *PlainGitClient) {
+		c.auth = &http.BasicAuth{Username: username, Password: password}
+	}
+}
+
+// WithSSHKeyFile authenticates SSH remotes with the private key in path,
+// decrypted with password if it is not empty. Host keys are verified against
+// the files in SSH_KNOWN_HOSTS or ~/.ssh/known_hosts.
+func WithSSHKeyFile(path, password string) PlainGitOption {
+	return func(c *PlainGitClient) {
+		c.sshKeyFile = path
+		c.sshKeyPassword = password
+	}
+}
+
+// WithAuthor sets the name and email recorded as author and committer.
+func WithAuthor(name, email string) PlainGitOption {
+	return func(c *PlainGitClient) {
+		c.authorName = name
+		c.authorEmail = email
+	}
+}
+
+// WithDirectPush makes CommitFile push its commits to branch, normally the
+// base branch, instead of the status branch. The push is a fast-forward, so
+// it fails if branch has moved since the commit it builds on was resolved.
+func WithDirectPush(branch string) PlainGitOption {
+	return func(c *PlainGitClient) {
+		c.pushBranch = branch
+	}
+}
+
+// NewPlainGitClient creates a PlainGitClient for the remote at remoteURL,
+// keeping its clone in workDir. An existing clone in workDir is reused.
+func NewPlainGitClient(remoteURL, workDir string, opts ...PlainGitOption) (*PlainGitClient, error) {
+	c := &PlainGitClient{
+		url:         remoteURL,
+		workDir:     workDir,
+		authorName:  DefaultAuthorName,
+		authorEmail: DefaultAuthorEmail,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.sshKeyFile != "" {
+		endpoint, err := transport.NewEndpoint(remoteURL)
+		if err != nil {
+			return nil, fmt.Errorf("parse remote URL: %w", err)
+		}
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		keys, err := ssh.NewPublicKeysFromFile(user, c.sshKeyFile, c.sshKeyPassword)
+		if err != nil {
+			return nil, fmt.Errorf("load SSH key %s: %w", c.sshKeyFile, err)
+		}
+		c.auth = keys
+	}
+
+	repo, err := gogit.PlainOpen(workDir)
+	if errors.Is(err, gogit.ErrRepositoryNotExists) {
+		repo, err = gogit.PlainInit(workDir, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open work directory %s: %w", workDir, err)
+	}
+	// Recreate the remote so a changed URL takes effect on a reused clone.
+	if err := repo.DeleteRemote(remoteName); err != nil && !errors.Is(err, gogit.ErrRemoteNotFound) {
+		return nil, fmt.Errorf("configure remote: %w", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{remoteURL}}); err != nil {
+		return nil, fmt.Errorf("configure remote: %w", err)
+	}
+	c.repo = repo
+
+	return c, nil
+}
+
+// FetchFile retrieves a file's content and blob SHA from the given ref, which
+// may be a branch name or a commit SHA.
+func (c *PlainGitClient) FetchFile(path, ref string) ([]byte, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	commit, err := c.commit(ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: %w", err)
+	}
+	file, err := commit.File(strings.Trim(path, "/"))
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, "", fmt.Errorf("fetch file %s: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: %w", err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch file: read blob: %w", err)
+	}
+
+	return []byte(content), file.Hash.String(), nil
+}
+
+// CommitFile creates a commit on top of baseSHA that sets path to content and
+// force-pushes it to branchName, creating the branch if it does not exist.
+// With WithDirectPush the commit is pushed to the configured branch instead,
+// and nothing is pushed if content is already on baseSHA.
+func (c *PlainGitClient) CommitFile(baseSHA, branchName, path, message string, content []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	base, err := c.commit(baseSHA)
+	if err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+	baseTree, err := base.Tree()
+	if err != nil {
+		return fmt.Errorf("commit file: read tree: %w", err)
+	}
+
+	blob := c.repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return fmt.Errorf("commit file: write blob: %w", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("commit file: write blob: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("commit file: write blob: %w", err)
+	}
+	blobHash, err := c.repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return fmt.Errorf("commit file: write blob: %w", err)
+	}
+
+	treeHash, err := c.writeTree(baseTree, strings.Split(strings.Trim(path, "/"), "/"), blobHash)
+	if err != nil {
+		return fmt.Errorf("commit file: write tree: %w", err)
+	}
+
+	head := base.Hash
+	if treeHash != base.TreeHash {
+		sig := object.Signature{Name: c.authorName, Email: c.authorEmail, When: time.Now()}
+		head, err = c.storeObject(&object.Commit{
+			Author:       sig,
+			Committer:    sig,
+			Message:      message,
+			TreeHash:     treeHash,
+			ParentHashes: []plumbing.Hash{base.Hash},
+		})
+		if err != nil {
+			return fmt.Errorf("commit file: write commit: %w", err)
+		}
+	}
+
+	if c.pushBranch != "" {
+		if head == base.Hash {
+			return nil
+		}
+		if err := c.push(head, c.pushBranch, false); err != nil {
+			return fmt.Errorf("commit file: %w", err)
+		}
+		return nil
+	}
+	if err := c.push(head, branchName, true); err != nil {
+		return fmt.Errorf("commit file: %w", err)
+	}
+	return nil
+}
+
+// CreatePR returns 0, as plain git has no pull requests. The branch pushed
+// by CommitFile is the proposal.
+func (c *PlainGitClient) CreatePR(title, body, head, base string) (int, error) {
+	return 0, nil
+}
+
+// UpdatePR does nothing, as plain git has no pull requests.
+func (c *PlainGitClient) UpdatePR(number int, title, body string) error {
+	return nil
+}
+
+// ClosePR does nothing, as plain git has no pull requests.
+func (c *PlainGitClient) ClosePR(number int) error {
+	return nil
+}
+
+// ListOpenPRs returns no pull requests, as plain git has none.
+func (c *PlainGitClient) ListOpenPRs(head string) ([]int, error) {
+	return nil, nil
+}
+
+// GetRef fetches the remote and returns the commit SHA that the given branch
+// points to.
+func (c *PlainGitClient) GetRef(branch string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.fetch(); err != nil {
+		return "", fmt.Errorf("get ref: %w", err)
+	}
+	hash, err := c.remoteBranch(branch)
+	if err != nil {
+		return "", fmt.Errorf("get ref %s: %w", branch, err)
+	}
+	return hash.String(), nil
+}
+
+// fetch updates the remote-tracking branches of the clone.
+func (c *PlainGitClient) fetch() error {
+	err := c.repo.Fetch(&gogit.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/remotes/" + remoteName + "/*"},
+		Auth:       c.auth,
+		Prune:      true,
+		Force:      true,
+	})
+	switch {
+	case err == nil, errors.Is(err, gogit.NoErrAlreadyUpToDate):
+		return nil
+	case errors.Is(err, transport.ErrEmptyRemoteRepository):
+		// No branches yet; lookups report ErrNotFound.
+		return nil
+	default:
+		return fmt.Errorf("fetch %s: %w", c.url, err)
+	}
+}
+
+// remoteBranch returns the commit of the remote-tracking branch as of the
+// last fetch.
+func (c *PlainGitClient) remoteBranch(branch string) (plumbing.Hash, error) {
+	ref, err := c.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, branch), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return plumbing.ZeroHash, ErrNotFound
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+// commit resolves ref, a branch name or a commit SHA, fetching the remote if
+// the commit is not in the clone yet.
+func (c *PlainGitClient) commit(ref string) (*object.Commit, error) {
+	var hash plumbing.Hash
+	if plumbing.IsHash(ref) {
+		hash = plumbing.NewHash(ref)
+		if commit, err := c.repo.CommitObject(hash); err == nil {
+			return commit, nil
+		}
+		if err := c.fetch(); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := c.fetch(); err != nil {
+			return nil, err
+		}
+		var err error
+		if hash, err = c.remoteBranch(ref); err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", ref, err)
+		}
+	}
+
+	commit, err := c.repo.CommitObject(hash)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, fmt.Errorf("resolve %s: %w", ref, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	return commit, nil
+}
+
+// writeTree stores a copy of tree, which may be nil, with the file at path
+// set to blob and returns its hash. Missing directories are created.
+func (c *PlainGitClient) writeTree(tree *object.Tree, path []string, blob plumbing.Hash) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	if tree != nil {
+		entries = append(entries, tree.Entries...)
+	}
+	i := -1
+	for j, e := range entries {
+		if e.Name == path[0] {
+			i = j
+			break
+		}
+	}
+
+	entry := object.TreeEntry{Name: path[0], Mode: filemode.Regular, Hash: blob}
+	if len(path) > 1 {
+		var sub *object.Tree
+		if i >= 0 && entries[i].Mode == filemode.Dir {
+			var err error
+			if sub, err = c.repo.TreeObject(entries[i].Hash); err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+		hash, err := c.writeTree(sub, path[1:], blob)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entry = object.TreeEntry{Name: path[0], Mode: filemode.Dir, Hash: hash}
+	} else if i >= 0 && entries[i].Mode == filemode.Executable {
+		entry.Mode = filemode.Executable
+	}
+
+	if i >= 0 {
+		entries[i] = entry
+	} else {
+		entries = append(entries, entry)
+	}
+	// Git orders tree entries by name, comparing directories as if their
+	// name ended in a slash.
+	sort.Slice(entries, func(a, b int) bool {
+		return treeSortKey(entries[a]) < treeSortKey(entries[b])
+	})
+
+	return c.storeObject(&object.Tree{Entries: entries})
+}
+
+func treeSortKey(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+// storeObject encodes obj into the clone and returns its hash.
+func (c *PlainGitClient) storeObject(obj interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	encoded := c.repo.Storer.NewEncodedObject()
+	if err := obj.Encode(encoded); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return c.repo.Storer.SetEncodedObject(encoded)
+}
+
+// push points the remote branch at commit. Without force only a
+// fast-forward is accepted.
+func (c *PlainGitClient) push(commit plumbing.Hash, branch string, force bool) error {
+	local := plumbing.NewBranchReferenceName(branch)
+	if err := c.repo.Storer.SetReference(plumbing.NewHashReference(local, commit)); err != nil {
+		return fmt.Errorf("push %s: %w", branch, err)
+	}
+	spec := config.RefSpec(local.String() + ":" + local.String())
+	if force {
+		spec = "+" + spec
+	}
+	err := c.repo.Push(&gogit.PushOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{spec},
+		Auth:       c.auth,
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("push %s: %w", branch, err)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+func init() {
+	// Serve file:// remotes in process, so the tests need no git binary.
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+// newBareRemote creates a bare repository whose main branch holds files and
+// returns its path and the SHA of main.
+func newBareRemote(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+
+	remote := t.TempDir()
+	if _, err := gogit.PlainInit(remote, true); err != nil {
+		t.Fatalf("init remote: %v", err)
+	}
+
+	src := t.TempDir()
+	repo, err := gogit.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("init source: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("worktree: %v", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatalf("add %s: %v", name, err)
+		}
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := wt.Commit("initial", &gogit.CommitOptions{Author: sig})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remote}}); err != nil {
+		t.Fatalf("create remote: %v", err)
+	}
+	if err := repo.Push(&gogit.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/main"}}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+
+	return remote, hash.String()
+}
+
+// remoteCommit returns the commit that branch points to in the bare
+// repository at remote.
+func remoteCommit(t *testing.T, remote, branch string) *object.Commit {
+	t.Helper()
+
+	repo, err := gogit.PlainOpen(remote)
+	if err != nil {
+		t.Fatalf("open remote: %v", err)
+	}
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		t.Fatalf("resolve %s: %v", branch, err)
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatalf("read commit: %v", err)
+	}
+	return commit
+}
+
+func fileContent(t *testing.T, commit *object.Commit, path string) string {
+	t.Helper()
+
+	file, err := commit.File(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return content
+}
+
+func newTestPlainGitClient(t *testing.T, remote string, opts ...PlainGitOption) *PlainGitClient {
+	t.Helper()
+
+	client, err := NewPlainGitClient(remote, t.TempDir(), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return client
+}
+
+func TestPlainGitGetRefAndFetchFile(t *testing.T) {
+	remote, sha := newBareRemote(t, map[string]string{"path/to/file.yaml": "hello world"})
+	client := newTestPlainGitClient(t, remote)
+
+	got, err := client.GetRef("main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != sha {
+		t.Fatalf("expected %s, got %s", sha, got)
+	}
+
+	for _, ref := range []string{"main", sha} {
+		content, blobSHA, err := client.FetchFile("path/to/file.yaml", ref)
+		if err != nil {
+			t.Fatalf("fetch at %s: unexpected error: %v", ref, err)
+		}
+		if string(content) != "hello world" || blobSHA == "" {
+			t.Fatalf("fetch at %s: expected %q with a blob SHA, got %q at %q", ref, "hello world", content, blobSHA)
+		}
+	}
+
+	if _, _, err := client.FetchFile("missing.yaml", "main"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing file, got %v", err)
+	}
+}
+
+func TestPlainGitGetRef_NotFound(t *testing.T) {
+	remote, _ := newBareRemote(t, map[string]string{"file.yaml": "x"})
+	client := newTestPlainGitClient(t, remote)
+
+	if _, err := client.GetRef("does-not-exist"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPlainGitCommitFile(t *testing.T) {
+	remote, base := newBareRemote(t, map[string]string{
+		"registry/clusters.yaml": "old",
+		"README.md":              "readme",
+	})
+	client := newTestPlainGitClient(t, remote, WithAuthor("bot", "bot@example.com"))
+
+	// Commit twice: the branch must be rebuilt on base, not stacked.
+	for _, content := range []string{"first", "second"} {
+		if err := client.CommitFile(base, "status", "registry/clusters.yaml", "update", []byte(content)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		commit := remoteCommit(t, remote, "status")
+		if len(commit.ParentHashes) != 1 || commit.ParentHashes[0].String() != base {
+			t.Fatalf("expected commit on base %s, got parents %v", base, commit.ParentHashes)
+		}
+		if commit.Author.Name != "bot" || commit.Author.Email != "bot@example.com" {
+			t.Fatalf("unexpected author %s <%s>", commit.Author.Name, commit.Author.Email)
+		}
+		if got := fileContent(t, commit, "registry/clusters.yaml"); got != content {
+			t.Fatalf("expected %q, got %q", content, got)
+		}
+		if got := fileContent(t, commit, "README.md"); got != "readme" {
+			t.Fatalf("expected other files to be kept, got README.md %q", got)
+		}
+	}
+
+	if got := remoteCommit(t, remote, "main").Hash.String(); got != base {
+		t.Fatalf("expected main to stay at %s, got %s", base, got)
+	}
+}
+
+func TestPlainGitCommitFile_DirectPush(t *testing.T) {
+	remote, base := newBareRemote(t, map[string]string{"registry.yaml": "old"})
+	client := newTestPlainGitClient(t, remote, WithDirectPush("main"))
+
+	if err := client.CommitFile(base, "status", "registry.yaml", "update", []byte("new")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	head := remoteCommit(t, remote, "main")
+	if head.ParentHashes[0].String() != base || fileContent(t, head, "registry.yaml") != "new" {
+		t.Fatalf("expected main to advance from %s with the new content", base)
+	}
+
+	// Unchanged content creates no commit.
+	if err := client.CommitFile(head.Hash.String(), "status", "registry.yaml", "update", []byte("new")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := remoteCommit(t, remote, "main").Hash; got != head.Hash {
+		t.Fatalf("expected main to stay at %s, got %s", head.Hash, got)
+	}
+
+	// A commit on a stale base must not overwrite main.
+	if err := client.CommitFile(base, "status", "registry.yaml", "update", []byte("stale")); err == nil {
+		t.Fatal("expected non-fast-forward push to fail")
+	}
+	if got := remoteCommit(t, remote, "main").Hash; got != head.Hash {
+		t.Fatalf("expected main to stay at %s, got %s", head.Hash, got)
+	}
+}
+
+func TestPlainGitReusesWorkDir(t *testing.T) {
+	remote, base := newBareRemote(t, map[string]string{"registry.yaml": "old"})
+	workDir := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		client, err := NewPlainGitClient(remote, workDir)
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", i, err)
+		}
+		if got, err := client.GetRef("main"); err != nil || got != base {
+			t.Fatalf("run %d: expected %s, got %s (%v)", i, base, got, err)
+		}
+	}
+}
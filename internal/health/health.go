@@ -0,0 +1,174 @@
+// Package health implements named readiness and liveness checks and serves
+// their results as JSON.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a single check run by Checks.
+const DefaultTimeout = 5 * time.Second
+
+// Check reports an error if the component it probes is unhealthy.
+type Check func(ctx context.Context) error
+
+// Checks is a registry of named checks. The zero value is an empty registry,
+// which always reports ok.
+type Checks struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]Check
+}
+
+// Add registers check under name, replacing any check of the same name.
+func (c *Checks) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checks == nil {
+		c.checks = make(map[string]Check)
+	}
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of running all checks. Status is "ok" only if every
+// check passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Healthy reports whether all checks passed.
+func (r Report) Healthy() bool {
+	return r.Status == "ok"
+}
+
+// Run executes all checks concurrently, each bounded by DefaultTimeout.
+func (c *Checks) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != "ok" {
+			report.Status = "failed"
+		}
+	}
+	return report
+}
+
+// run executes check. A check that ignores ctx keeps running in the
+// background, but its result is given up on once ctx is done.
+func run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := Result{Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Handler runs the checks on every request and responds with the Report,
+// using status 503 if any check failed.
+func (c *Checks) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Cached wraps check so that its result is reused for ttl. Use it for checks
+// that call rate-limited external APIs, as probes run every few seconds.
+// Callers arriving while the check runs wait for its result instead of
+// starting another run, but no longer than their own ctx allows.
+func Cached(check Check, ttl time.Duration) Check {
+	var (
+		mu       sync.Mutex
+		checked  time.Time
+		last     error
+		inflight *cachedCall
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			defer mu.Unlock()
+			return last
+		}
+		if call := inflight; call != nil {
+			mu.Unlock()
+			select {
+			case <-call.done:
+				return call.err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		call := &cachedCall{done: make(chan struct{})}
+		inflight = call
+		mu.Unlock()
+
+		call.err = check(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inflight = nil
+		close(call.done)
+		if ctx.Err() == nil {
+			// Do not keep a failure caused by the caller giving up.
+			last, checked = call.err, time.Now()
+		}
+		return call.err
+	}
+}
+
+// cachedCall is a run of a Cached check that later callers wait for.
+type cachedCall struct {
+	done chan struct{}
+	err  error
+}
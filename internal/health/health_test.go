@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChecks_Handler(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "no checks",
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+		},
+		{
+			name: "all passing",
+			checks: map[string]Check{
+				"a": func(context.Context) error { return nil },
+				"b": func(context.Context) error { return nil },
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+		},
+		{
+			name: "one failing",
+			checks: map[string]Check{
+				"a": func(context.Context) error { return nil },
+				"b": func(context.Context) error { return errors.New("down") },
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "failed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := &Checks{}
+			for name, check := range tc.checks {
+				checks.Add(name, check)
+			}
+
+			rec := httptest.NewRecorder()
+			checks.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rec.Code)
+			}
+			var report Report
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if report.Status != tc.wantStatus {
+				t.Errorf("expected status %q, got %q", tc.wantStatus, report.Status)
+			}
+			if len(report.Checks) != len(tc.checks) {
+				t.Fatalf("expected %d check results, got %d", len(tc.checks), len(report.Checks))
+			}
+			if r, ok := report.Checks["b"]; ok && tc.wantStatus == "failed" && r.Error != "down" {
+				t.Errorf("expected error 'down' for check b, got %+v", r)
+			}
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	check := Cached(func(context.Context) error {
+		calls++
+		return errors.New("down")
+	}, 20*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if err := check(context.Background()); err == nil {
+			t.Fatal("expected cached error")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call within ttl, got %d", calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	check(context.Background())
+	if calls != 2 {
+		t.Fatalf("expected check to run again after ttl, got %d calls", calls)
+	}
+}
+
+func TestChecks_RunHungCheck(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var c Checks
+	// The check ignores its context, like a client without a timeout.
+	c.Add("hung", func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report := c.Run(ctx)
+	if report.Healthy() || report.Checks["hung"].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected the hung check to fail on timeout, got %+v", report)
+	}
+}
+
+func TestCached_HungCheck(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	check := Cached(func(context.Context) error {
+		calls++
+		<-release
+		return errors.New("down")
+	}, time.Minute)
+
+	first := make(chan error, 1)
+	go func() { first <- check(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	// A later probe gives up on its own deadline instead of queueing.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := check(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the waiting probe to time out, got %v", err)
+	}
+
+	close(release)
+	if err := <-first; err == nil {
+		t.Fatal("expected the check error")
+	}
+	if err := check(context.Background()); err == nil || calls != 1 {
+		t.Fatalf("expected the cached error from a single call, got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,113 @@
+package informer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/signing"
+)
+
+// bearerTransport adds an Authorization header to every request sent to the
+// collector.
+type bearerTransport struct {
+	base  http.RoundTripper
+	token func() (string, error)
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// WithBearerToken authenticates requests to the collector with token.
+func WithBearerToken(token string) ClaimWatcherOption {
+	return withTokenSource(func() (string, error) {
+		return token, nil
+	})
+}
+
+// WithBearerTokenFile authenticates requests to the collector with the token
+// stored in path. The file is read on every request, so a rotated token from
+// a mounted Secret is picked up without a restart.
+func WithBearerTokenFile(path string) ClaimWatcherOption {
+	return withTokenSource(func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	})
+}
+
+func withTokenSource(token func() (string, error)) ClaimWatcherOption {
+	return func(w *ClaimWatcher) {
+		base := w.httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		w.httpClient.Transport = &bearerTransport{base: base, token: token}
+	}
+}
+
+// WithTLSConfig uses cfg for HTTPS connections to the collector, e.g. to
+// present a client certificate or trust a private CA. It can be combined with
+// the bearer token options in any order.
+func WithTLSConfig(cfg *tls.Config) ClaimWatcherOption {
+	return func(w *ClaimWatcher) {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = cfg
+
+		// Replace the innermost transport below any bearer or signing
+		// wrappers added by earlier options.
+		rt := &w.httpClient.Transport
+		for {
+			switch wrapper := (*rt).(type) {
+			case *bearerTransport:
+				rt = &wrapper.base
+				continue
+			case *signingTransport:
+				rt = &wrapper.base
+				continue
+			}
+			break
+		}
+		*rt = t
+	}
+}
+
+// signingTransport signs every request sent to the collector with a shared
+// secret, see package signing.
+type signingTransport struct {
+	base   http.RoundTripper
+	secret []byte
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := signing.SignRequest(req, t.secret, time.Now()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// WithRequestSigning signs requests to the collector with secret. Every
+// retry is signed anew, so replayed deliveries from the outbox carry a fresh
+// timestamp and nonce.
+func WithRequestSigning(secret []byte) ClaimWatcherOption {
+	return func(w *ClaimWatcher) {
+		base := w.httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		w.httpClient.Transport = &signingTransport{base: base, secret: secret}
+	}
+}
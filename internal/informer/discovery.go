@@ -0,0 +1,204 @@
+package informer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// XRDGVR is the GroupVersionResource of Crossplane CompositeResourceDefinitions.
+var XRDGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.crossplane.io",
+	Version:  "v1",
+	Resource: "compositeresourcedefinitions",
+}
+
+// ClaimGVRFromXRD derives the claim GVR offered by a CompositeResourceDefinition
+// from spec.group, spec.claimNames.plural and its served versions, preferring
+// the referenceable version. It returns false for XRDs that offer no claim.
+func ClaimGVRFromXRD(xrd *unstructured.Unstructured) (schema.GroupVersionResource, bool) {
+	group, _, _ := unstructured.NestedString(xrd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(xrd.Object, "spec", "claimNames", "plural")
+	if group == "" || plural == "" {
+		return schema.GroupVersionResource{}, false
+	}
+
+	versions, _, _ := unstructured.NestedSlice(xrd.Object, "spec", "versions")
+	var version string
+	for _, v := range versions {
+		ver, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		served, _, _ := unstructured.NestedBool(ver, "served")
+		if !served {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(ver, "name")
+		if referenceable, _, _ := unstructured.NestedBool(ver, "referenceable"); referenceable {
+			version = name
+			break
+		}
+		if version == "" {
+			version = name
+		}
+	}
+	if version == "" {
+		return schema.GroupVersionResource{}, false
+	}
+
+	return schema.GroupVersionResource{Group: group, Version: version, Resource: plural}, true
+}
+
+// claimInformer is a claim informer started by AddGVR.
+type claimInformer struct {
+	done   <-chan struct{}
+	cancel context.CancelFunc
+	synced cache.InformerSynced
+}
+
+// hasSynced reports whether the informer delivered its initial list, or was
+// stopped and never will.
+func (c *claimInformer) hasSynced() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return c.synced()
+	}
+}
+
+// startXRDDiscovery watches CompositeResourceDefinitions and starts or stops
+// claim informers as XRDs offering claims come and go. It returns once the
+// claims offered by the XRDs found initially are in sync.
+func (w *ClaimWatcher) startXRDDiscovery(ctx context.Context) error {
+	// XRDs are cluster-scoped, so this informer is never namespace-filtered.
+	informer := dynamicinformer.NewDynamicSharedInformerFactory(w.dynamicClient, 0).
+		ForResource(XRDGVR).Informer()
+
+	reg, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if xrd, ok := obj.(*unstructured.Unstructured); ok {
+				w.syncXRD(ctx, xrd)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if xrd, ok := newObj.(*unstructured.Unstructured); ok {
+				w.syncXRD(ctx, xrd)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if xrd, ok := obj.(*unstructured.Unstructured); ok {
+				w.forgetXRD(xrd.GetName())
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("add XRD event handler: %w", err)
+	}
+
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), reg.HasSynced) {
+		return fmt.Errorf("sync XRD informer cache: %w", ctx.Err())
+	}
+
+	// The handler has seen every XRD by now, so the claim informers for
+	// them are running.
+	w.mu.Lock()
+	synced := make([]cache.InformerSynced, 0, len(w.running))
+	for _, c := range w.running {
+		synced = append(synced, c.hasSynced)
+	}
+	w.mu.Unlock()
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("sync discovered claim informer caches: %w", ctx.Err())
+	}
+	return nil
+}
+
+// syncXRD makes sure the claim GVR currently offered by xrd is watched,
+// replacing the informer for a previously offered version.
+func (w *ClaimWatcher) syncXRD(ctx context.Context, xrd *unstructured.Unstructured) {
+	gvr, ok := ClaimGVRFromXRD(xrd)
+
+	w.mu.Lock()
+	prev, known := w.xrdGVRs[xrd.GetName()]
+	if ok {
+		w.xrdGVRs[xrd.GetName()] = gvr
+	} else {
+		delete(w.xrdGVRs, xrd.GetName())
+	}
+	w.mu.Unlock()
+
+	if known && (!ok || prev != gvr) {
+		w.RemoveGVR(prev)
+	}
+	if ok {
+		w.AddGVR(ctx, gvr)
+	}
+}
+
+func (w *ClaimWatcher) forgetXRD(name string) {
+	w.mu.Lock()
+	gvr, known := w.xrdGVRs[name]
+	delete(w.xrdGVRs, name)
+	w.mu.Unlock()
+
+	if known {
+		w.RemoveGVR(gvr)
+	}
+}
+
+// AddGVR starts an informer for gvr that runs until RemoveGVR is called or
+// ctx is cancelled. GVRs that are already watched are ignored.
+func (w *ClaimWatcher) AddGVR(ctx context.Context, gvr schema.GroupVersionResource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.running[gvr]; ok {
+		return
+	}
+	for _, static := range w.gvrs {
+		if static == gvr {
+			return
+		}
+	}
+
+	informer := dynamicinformer.NewFilteredDynamicInformer(
+		w.dynamicClient, gvr, w.namespace, 0, cache.Indexers{}, nil,
+	).Informer()
+	reg, err := informer.AddEventHandler(w.eventHandler(gvr))
+	if err != nil {
+		slog.Error("add event handler", "gvr", gvr.String(), "error", err)
+		return
+	}
+
+	gvrCtx, cancel := context.WithCancel(ctx)
+	w.running[gvr] = &claimInformer{done: gvrCtx.Done(), cancel: cancel, synced: reg.HasSynced}
+	go informer.Run(gvrCtx.Done())
+
+	slog.Info("watching claim GVR", "gvr", gvr.String())
+}
+
+// RemoveGVR stops the informer started by AddGVR for gvr.
+func (w *ClaimWatcher) RemoveGVR(gvr schema.GroupVersionResource) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	c, ok := w.running[gvr]
+	if !ok {
+		return
+	}
+	c.cancel()
+	delete(w.running, gvr)
+
+	slog.Info("stopped watching claim GVR", "gvr", gvr.String())
+}
@@ -0,0 +1,205 @@
+package informer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newXRD(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apiextensions.crossplane.io/v1",
+			"kind":       "CompositeResourceDefinition",
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+			"spec": spec,
+		},
+	}
+}
+
+func TestClaimGVRFromXRD(t *testing.T) {
+	cases := []struct {
+		name string
+		spec map[string]interface{}
+		want schema.GroupVersionResource
+		ok   bool
+	}{
+		{
+			name: "referenceable version preferred",
+			spec: map[string]interface{}{
+				"group":      "database.example.org",
+				"claimNames": map[string]interface{}{"kind": "PostgreSQL", "plural": "postgresqls"},
+				"versions": []interface{}{
+					map[string]interface{}{"name": "v1alpha1", "served": true, "referenceable": false},
+					map[string]interface{}{"name": "v1beta1", "served": true, "referenceable": true},
+				},
+			},
+			want: schema.GroupVersionResource{Group: "database.example.org", Version: "v1beta1", Resource: "postgresqls"},
+			ok:   true,
+		},
+		{
+			name: "first served version",
+			spec: map[string]interface{}{
+				"group":      "storage.example.org",
+				"claimNames": map[string]interface{}{"kind": "Bucket", "plural": "buckets"},
+				"versions": []interface{}{
+					map[string]interface{}{"name": "v1alpha1", "served": false},
+					map[string]interface{}{"name": "v1alpha2", "served": true},
+				},
+			},
+			want: schema.GroupVersionResource{Group: "storage.example.org", Version: "v1alpha2", Resource: "buckets"},
+			ok:   true,
+		},
+		{
+			name: "no claimNames",
+			spec: map[string]interface{}{
+				"group": "storage.example.org",
+				"versions": []interface{}{
+					map[string]interface{}{"name": "v1", "served": true, "referenceable": true},
+				},
+			},
+		},
+		{
+			name: "no served version",
+			spec: map[string]interface{}{
+				"group":      "storage.example.org",
+				"claimNames": map[string]interface{}{"kind": "Bucket", "plural": "buckets"},
+				"versions": []interface{}{
+					map[string]interface{}{"name": "v1", "served": false},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ClaimGVRFromXRD(newXRD("xrd", tc.spec))
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("expected %v (%v), got %v (%v)", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestXRDDiscovery(t *testing.T) {
+	received := make(chan statusPayload, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p statusPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err == nil {
+			received <- p
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	claimGVR := schema.GroupVersionResource{Group: "database.example.org", Version: "v1alpha1", Resource: "postgresqls"}
+	xrd := newXRD("xpostgresqls.database.example.org", map[string]interface{}{
+		"group":      "database.example.org",
+		"claimNames": map[string]interface{}{"kind": "PostgreSQL", "plural": "postgresqls"},
+		"versions": []interface{}{
+			map[string]interface{}{"name": "v1alpha1", "served": true, "referenceable": true},
+		},
+	})
+	claim := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "database.example.org/v1alpha1",
+			"kind":       "PostgreSQL",
+			"metadata": map[string]interface{}{
+				"name":      "my-db",
+				"namespace": "default",
+			},
+		},
+	}
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			XRDGVR:   "CompositeResourceDefinitionList",
+			claimGVR: "PostgreSQLList",
+		},
+		xrd, claim,
+	)
+
+	w := NewClaimWatcher(client, ts.URL, "cluster-01", nil, "", WithXRDDiscovery(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	select {
+	case p := <-received:
+		if p.ClaimRef != "default/my-db" || p.Kind != "PostgreSQL" {
+			t.Errorf("unexpected payload: %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the discovered claim to be reported")
+	}
+
+	w.forgetXRD(xrd.GetName())
+	w.mu.Lock()
+	_, running := w.running[claimGVR]
+	w.mu.Unlock()
+	if running {
+		t.Fatal("expected the claim informer to stop once its XRD is gone")
+	}
+}
+
+func TestXRDDiscovery_ReadyAfterClaimsSynced(t *testing.T) {
+	// Deliveries keep failing, so synced claims stay pending.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	claimGVR := schema.GroupVersionResource{Group: "database.example.org", Version: "v1alpha1", Resource: "postgresqls"}
+	xrd := newXRD("xpostgresqls.database.example.org", map[string]interface{}{
+		"group":      "database.example.org",
+		"claimNames": map[string]interface{}{"kind": "PostgreSQL", "plural": "postgresqls"},
+		"versions": []interface{}{
+			map[string]interface{}{"name": "v1alpha1", "served": true},
+		},
+	})
+	claim := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "database.example.org/v1alpha1",
+			"kind":       "PostgreSQL",
+			"metadata": map[string]interface{}{
+				"name":      "my-db",
+				"namespace": "default",
+			},
+		},
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			XRDGVR:   "CompositeResourceDefinitionList",
+			claimGVR: "PostgreSQLList",
+		},
+		xrd, claim,
+	)
+
+	w := NewClaimWatcher(client, ts.URL, "cluster-01", nil, "", WithXRDDiscovery(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	// Poll without sleeping to catch the watcher as soon as it is ready.
+	deadline := time.Now().Add(5 * time.Second)
+	for !w.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the watcher to become ready")
+		}
+		goruntime.Gosched()
+	}
+	if n := w.PendingDeliveries(); n != 1 {
+		t.Fatalf("expected the discovered claim to be seen before ready, got %d pending", n)
+	}
+}
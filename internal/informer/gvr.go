@@ -0,0 +1,76 @@
+package informer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ParseGVR parses a single "group/version/resource" string.
+func ParseGVR(s string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return schema.GroupVersionResource{}, fmt.Errorf("invalid GVR %q (want group/version/resource)", s)
+	}
+	return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+}
+
+// gvrLabel formats gvr as "group/version/resource", the form ParseGVR
+// accepts, for logs and metric labels.
+func gvrLabel(gvr schema.GroupVersionResource) string {
+	return gvr.Group + "/" + gvr.Version + "/" + gvr.Resource
+}
+
+// ParseGVRList parses a comma-separated list of "group/version/resource"
+// strings. Empty items are ignored.
+func ParseGVRList(s string) ([]schema.GroupVersionResource, error) {
+	var gvrs []schema.GroupVersionResource
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		gvr, err := ParseGVR(item)
+		if err != nil {
+			return nil, err
+		}
+		gvrs = append(gvrs, gvr)
+	}
+	return gvrs, nil
+}
+
+type gvrFile struct {
+	GVRs []struct {
+		Group    string `yaml:"group"`
+		Version  string `yaml:"version"`
+		Resource string `yaml:"resource"`
+	} `yaml:"gvrs"`
+}
+
+// LoadGVRFile reads the claim GVRs to watch from a YAML file of the form:
+//
+//	gvrs:
+//	  - group: database.example.org
+//	    version: v1alpha1
+//	    resource: postgresqls
+func LoadGVRFile(path string) ([]schema.GroupVersionResource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read GVR file: %w", err)
+	}
+	var f gvrFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse GVR file: %w", err)
+	}
+
+	gvrs := make([]schema.GroupVersionResource, 0, len(f.GVRs))
+	for i, g := range f.GVRs {
+		if g.Version == "" || g.Resource == "" {
+			return nil, fmt.Errorf("GVR file entry %d: version and resource are required", i)
+		}
+		gvrs = append(gvrs, schema.GroupVersionResource{Group: g.Group, Version: g.Version, Resource: g.Resource})
+	}
+	return gvrs, nil
+}
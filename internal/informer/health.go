@@ -0,0 +1,36 @@
+package informer
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+)
+
+// Handler serves the agent's probe and metrics endpoints:
+//
+//   - /healthz reports ok while the process is serving
+//   - /readyz reports ready once the informer caches have synced
+//   - /metrics serves the informer metrics
+func (w *ClaimWatcher) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", w.handleHealthz)
+	mux.HandleFunc("GET /readyz", w.handleReadyz)
+	mux.Handle("GET /metrics", metrics.InformerHandler())
+	return mux
+}
+
+func (w *ClaimWatcher) handleHealthz(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(map[string]string{"status": "ok"})
+}
+
+func (w *ClaimWatcher) handleReadyz(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if !w.Ready() {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(rw).Encode(map[string]string{"status": "not ready"})
+		return
+	}
+	json.NewEncoder(rw).Encode(map[string]string{"status": "ready"})
+}
@@ -0,0 +1,62 @@
+package informer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestHandler_Readyz(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+	w := NewClaimWatcher(nil, "http://collector", "cluster-01", []schema.GroupVersionResource{gvr}, "default")
+	h := w.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 before cache sync, got %d", rec.Code)
+	}
+
+	w.synced.Store(true)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after cache sync, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 from healthz, got %d", rec.Code)
+	}
+}
+
+func TestHandler_DeliveryMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	w := newQueueTestWatcher(ts.URL)
+	w.eventHandler(schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}).
+		OnAdd(newQueueTestClaim("Resource is available"), false)
+	w.processNextItem()
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`machinery_status_informer_claim_events_total{event="add",gvr="example.org/v1/postgresqls"}`,
+		`machinery_status_informer_deliveries_total{gvr="example.org/v1/postgresqls",outcome="sent"}`,
+		`machinery_status_informer_delivery_last_success_timestamp_seconds{gvr="example.org/v1/postgresqls"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected metrics to contain %s", want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package informer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Outbox spools undelivered claim states to a directory so they survive
+// informer restarts. It keeps one file per claim holding only the latest
+// state, so the spool never grows beyond the number of claims.
+type Outbox struct {
+	dir string
+
+	mu  sync.Mutex
+	seq uint64
+	// spooled holds the keys of the claims with a record.
+	spooled map[string]bool
+}
+
+// outboxTempPattern names records being written. Leftovers from a crash are
+// removed when the outbox is opened.
+const outboxTempPattern = ".tmp-*"
+
+// NewOutbox opens (or creates) an outbox in dir.
+func NewOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create outbox dir: %w", err)
+	}
+	tmps, err := filepath.Glob(filepath.Join(dir, outboxTempPattern))
+	if err != nil {
+		return nil, fmt.Errorf("find outbox temp files: %w", err)
+	}
+	for _, tmp := range tmps {
+		if err := os.Remove(tmp); err != nil {
+			return nil, fmt.Errorf("remove outbox temp file: %w", err)
+		}
+	}
+	return &Outbox{dir: dir, spooled: map[string]bool{}}, nil
+}
+
+// Save persists d as the latest state of its claim, replacing an older one,
+// and assigns it the next sequence number.
+func (o *Outbox) Save(d *delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+	d.Seq = o.seq
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("marshal outbox record: %w", err)
+	}
+
+	// Write to a temp file first and sync it before the rename, so a crash
+	// never leaves a truncated record.
+	tmp, err := os.CreateTemp(o.dir, outboxTempPattern)
+	if err != nil {
+		return fmt.Errorf("create outbox record: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write outbox record: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("sync outbox record: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("close outbox record: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path(d.key())); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rename outbox record: %w", err)
+	}
+	o.spooled[d.key()] = true
+	return nil
+}
+
+// Remove deletes the spooled state of the claim identified by key, if any.
+func (o *Outbox) Remove(key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.spooled[key] {
+		return nil
+	}
+	if err := os.Remove(o.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove outbox record: %w", err)
+	}
+	delete(o.spooled, key)
+	return nil
+}
+
+// Load returns all spooled states ordered by sequence number, oldest first.
+// Unreadable records are skipped.
+func (o *Outbox) Load() ([]*delivery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	files, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read outbox dir: %w", err)
+	}
+
+	var records []*delivery
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(o.dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read outbox record: %w", err)
+		}
+		var d delivery
+		if err := json.Unmarshal(data, &d); err != nil || d.ClaimRef == "" {
+			continue
+		}
+		records = append(records, &d)
+		o.spooled[d.key()] = true
+		if d.Seq > o.seq {
+			o.seq = d.Seq
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	return records, nil
+}
+
+func (o *Outbox) path(key string) string {
+	return filepath.Join(o.dir, url.PathEscape(key)+".json")
+}
@@ -0,0 +1,204 @@
+package informer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutbox_CompactsAndOrders(t *testing.T) {
+	dir := t.TempDir()
+	o, err := NewOutbox(dir)
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+
+	o.Save(&delivery{ClaimRef: "default/a", GVR: testGVR, Payload: &statusPayload{ClaimRef: "default/a", StatusMessage: "Creating"}})
+	o.Save(&delivery{ClaimRef: "default/b", GVR: testGVR, Deleted: true})
+	o.Save(&delivery{ClaimRef: "default/a", GVR: testGVR, Payload: &statusPayload{ClaimRef: "default/a", StatusMessage: "Available"}})
+	// A claim of another resource may share the namespace and name.
+	o.Save(&delivery{ClaimRef: "default/a", GVR: "example.org/v1/buckets", Deleted: true})
+
+	// Reopen to make sure the records and the sequence survive a restart.
+	o, err = NewOutbox(dir)
+	if err != nil {
+		t.Fatalf("reopen outbox: %v", err)
+	}
+	records, err := o.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 compacted records, got %d", len(records))
+	}
+	if records[0].ClaimRef != "default/b" || !records[0].Deleted {
+		t.Errorf("expected the deletion of default/b first, got %+v", records[0])
+	}
+	if records[1].ClaimRef != "default/a" || records[1].Payload.StatusMessage != "Available" {
+		t.Errorf("expected the latest state of default/a next, got %+v", records[1])
+	}
+	if records[2].GVR != "example.org/v1/buckets" || !records[2].Deleted {
+		t.Errorf("expected the deletion of the bucket default/a last, got %+v", records[2])
+	}
+
+	next := &delivery{ClaimRef: "default/c", GVR: testGVR, Deleted: true}
+	o.Save(next)
+	if next.Seq != 5 {
+		t.Errorf("expected sequence to continue at 5, got %d", next.Seq)
+	}
+
+	if err := o.Remove(testGVR + "/default/a"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	records, _ = o.Load()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records after remove, got %d", len(records))
+	}
+	// Claims without a record are ignored.
+	if err := o.Remove(testGVR + "/default/unknown"); err != nil {
+		t.Fatalf("remove unknown claim: %v", err)
+	}
+}
+
+func TestOutbox_SweepsTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	tmp := filepath.Join(dir, ".tmp-123")
+	if err := os.WriteFile(tmp, []byte(`{"seq":1`), 0o600); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	if _, err := NewOutbox(dir); err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Fatalf("expected leftover temp file to be removed, got %v", err)
+	}
+}
+
+func TestOutbox_SpoolsFailedDeliveries(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		available bool
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	o, err := NewOutbox(dir)
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	w := newQueueTestWatcher(ts.URL)
+	w.outbox = o
+
+	w.enqueue(testGVR, newQueueTestClaim("Available"), false)
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected nothing spooled before delivery, got %d files", len(files))
+	}
+
+	w.processNextItem()
+	if records, _ := o.Load(); len(records) != 1 || records[0].Payload.StatusMessage != "Available" {
+		t.Fatalf("expected the failed state to be spooled, got %+v", records)
+	}
+
+	mu.Lock()
+	available = true
+	mu.Unlock()
+	w.processNextItem()
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected the record to be removed after delivery, got %d files", len(files))
+	}
+}
+
+func TestOutbox_SpooledOnShutdown(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	o, err := NewOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	w := newQueueTestWatcher(ts.URL)
+	w.outbox = o
+	w.enqueue(testGVR, newQueueTestClaim("Available"), false)
+
+	// Shut the queue down first so that no worker delivers the claim.
+	w.queue.ShutDown()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.runWorkers(ctx)
+
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("expected no delivery after shutdown, got %d requests", n)
+	}
+	if records, _ := o.Load(); len(records) != 1 || records[0].ClaimRef != "default/my-db" {
+		t.Fatalf("expected the pending claim to be spooled, got %+v", records)
+	}
+}
+
+func TestOutbox_ReplayedOnStart(t *testing.T) {
+	received := make(chan statusPayload, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p statusPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		received <- p
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	o, err := NewOutbox(dir)
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	o.Save(&delivery{
+		ClaimRef: "default/my-db",
+		Payload:  &statusPayload{Cluster: "cluster-01", ClaimRef: "default/my-db", StatusMessage: "Available"},
+	})
+
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", nil, "", WithOutbox(o))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	select {
+	case p := <-received:
+		if p.ClaimRef != "default/my-db" || p.StatusMessage != "Available" {
+			t.Errorf("unexpected payload: %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the spooled status to be delivered")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, _ := os.ReadDir(dir)
+		if len(files) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected outbox to be drained, %d files left", len(files))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
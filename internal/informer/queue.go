@@ -0,0 +1,391 @@
+package informer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// sendWorkers is the number of goroutines delivering queued claims.
+	sendWorkers = 4
+
+	// maxBatchSize caps the number of claims sent in one batch request; it
+	// matches the limit of the collector's batch endpoint.
+	maxBatchSize = 1000
+
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 5 * time.Minute
+)
+
+// delivery is the latest state of a claim waiting to be sent to the collector.
+// It is also the record format of the Outbox.
+type delivery struct {
+	Seq      uint64 `json:"seq"`
+	ClaimRef string `json:"claimRef"`
+	// GVR is the claim's resource as "group/version/resource", used as
+	// metric label and, together with ClaimRef, to identify the claim.
+	GVR string `json:"gvr,omitempty"`
+	// Kind is the claim's kind, reported along with deletions.
+	Kind    string         `json:"kind,omitempty"`
+	Deleted bool           `json:"deleted,omitempty"`
+	Payload *statusPayload `json:"payload,omitempty"`
+	// TraceParent identifies the span of the informer event, which the
+	// delivery continues.
+	TraceParent string `json:"traceParent,omitempty"`
+
+	// spooled is set once the state has been written to the outbox. It is
+	// guarded by ClaimWatcher.mu.
+	spooled bool
+}
+
+// key identifies the claim of d in the pending map, the send queue and the
+// outbox. Claims of different resources may share a namespace and name, so
+// the claimRef alone is not unique.
+func (d *delivery) key() string {
+	return d.GVR + "/" + d.ClaimRef
+}
+
+func newSendQueue() workqueue.TypedRateLimitingInterface[string] {
+	return workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](retryBaseDelay, retryMaxDelay),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "claim-status"},
+	)
+}
+
+// enqueue records claim, a resource of gvr, as its latest state and schedules
+// it for delivery. A pending older state of the same claim is replaced.
+func (w *ClaimWatcher) enqueue(gvr string, claim *unstructured.Unstructured, deleted bool) {
+	d := &delivery{ClaimRef: claimRefOf(claim), GVR: gvr, Kind: claim.GetKind(), Deleted: deleted}
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "ClaimWatcher.event", trace.WithAttributes(
+		attribute.String("gvr", gvr),
+		attribute.String("claim.ref", d.ClaimRef),
+		attribute.Bool("claim.deleted", deleted),
+	))
+	defer span.End()
+	d.TraceParent = tracing.TraceParent(ctx)
+
+	if !deleted {
+		payload, err := w.buildPayload(claim)
+		if err != nil {
+			slog.Error("build status payload", "claimRef", d.ClaimRef, "error", err)
+			tracing.End(span, err)
+			return
+		}
+		d.Payload = payload
+	}
+	w.schedule(d)
+}
+
+// schedule makes d the pending state of its claim. It only reaches the outbox
+// if its delivery fails or the watcher stops before delivering it.
+func (w *ClaimWatcher) schedule(d *delivery) {
+	w.mu.Lock()
+	w.pending[d.key()] = d
+	w.mu.Unlock()
+
+	w.queue.Add(d.key())
+}
+
+// replayOutbox schedules the states left in the outbox by a previous run,
+// oldest first.
+func (w *ClaimWatcher) replayOutbox() error {
+	records, err := w.outbox.Load()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	for _, d := range records {
+		d.spooled = true
+		w.pending[d.key()] = d
+	}
+	w.mu.Unlock()
+
+	for _, d := range records {
+		w.queue.Add(d.key())
+	}
+	if len(records) > 0 {
+		slog.Info("replaying outbox", "records", len(records))
+	}
+	return nil
+}
+
+// runWorkers delivers queued claims until ctx is cancelled. With a batch
+// window a single worker sends coalesced batches, otherwise sendWorkers
+// workers send one claim per request. Once the workers have finished their
+// current requests, the claims still pending are spooled to the outbox.
+func (w *ClaimWatcher) runWorkers(ctx context.Context) {
+	var wg sync.WaitGroup
+	if w.batchWindow > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w.processNextBatch() {
+			}
+		}()
+	} else {
+		for i := 0; i < sendWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for w.processNextItem() {
+				}
+			}()
+		}
+	}
+	<-ctx.Done()
+	w.queue.ShutDown()
+	wg.Wait()
+	w.spoolPending()
+}
+
+// processNextItem sends the latest state of the next queued claim. Claims the
+// collector rejects for good are dropped; other failed deliveries are
+// requeued with exponential backoff. It returns false once the queue has been
+// shut down.
+func (w *ClaimWatcher) processNextItem() bool {
+	key, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(key)
+	// The queue hands out the remaining keys after a shutdown; they are
+	// spooled instead of delivered.
+	if w.queue.ShuttingDown() {
+		return true
+	}
+
+	d, ok := w.pendingDelivery(key)
+	if !ok {
+		w.queue.Forget(key)
+		return true
+	}
+
+	if err := w.deliver(d); err != nil {
+		if rejected(err) {
+			slog.Error("collector rejected claim, dropping", "claimRef", d.ClaimRef, "error", err)
+			metrics.ObserveDelivery(d.GVR, "dropped")
+			w.complete(key, d)
+			return true
+		}
+		metrics.ObserveDelivery(d.GVR, "failed")
+		w.retry(key, err)
+		return true
+	}
+	metrics.ObserveDelivery(d.GVR, "sent")
+	w.complete(key, d)
+	return true
+}
+
+// processNextBatch waits for a queued claim, collects everything else queued
+// within the batch window and sends it as one batch. Items the collector
+// rejects as invalid or forbidden are dropped, as are all items of a batch
+// the collector rejects for good; all others that fail are retried. It
+// returns false once the queue has been shut down.
+func (w *ClaimWatcher) processNextBatch() bool {
+	key, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	keys := []string{key}
+
+	time.Sleep(w.batchWindow)
+	for len(keys) < maxBatchSize && w.queue.Len() > 0 {
+		key, shutdown := w.queue.Get()
+		if shutdown {
+			break
+		}
+		keys = append(keys, key)
+	}
+	defer func() {
+		for _, key := range keys {
+			w.queue.Done(key)
+		}
+	}()
+	if w.queue.ShuttingDown() {
+		return true
+	}
+
+	var batch []*delivery
+	for _, key := range keys {
+		d, ok := w.pendingDelivery(key)
+		if !ok {
+			w.queue.Forget(key)
+			continue
+		}
+		batch = append(batch, d)
+	}
+	if len(batch) == 0 {
+		return true
+	}
+
+	// A batch serves many events, so its span links to them instead of
+	// continuing one of their traces.
+	links := make([]trace.Link, 0, len(batch))
+	for _, d := range batch {
+		if link, ok := tracing.Link(d.TraceParent, attribute.String("claim.ref", d.ClaimRef)); ok {
+			links = append(links, link)
+		}
+	}
+	ctx, span := tracing.Tracer().Start(context.Background(), "ClaimWatcher.sendBatch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("batch.size", len(batch))),
+	)
+	results, err := w.postBatch(ctx, batch)
+	tracing.End(span, err)
+	if err != nil && rejected(err) {
+		for _, d := range batch {
+			slog.Error("collector rejected batch, dropping claim", "claimRef", d.ClaimRef, "error", err)
+			metrics.ObserveDelivery(d.GVR, "dropped")
+			w.complete(d.key(), d)
+		}
+		return true
+	}
+	if err != nil {
+		for _, d := range batch {
+			metrics.ObserveDelivery(d.GVR, "failed")
+			w.retry(d.key(), err)
+		}
+		return true
+	}
+
+	for i, d := range batch {
+		switch r := results[i]; r.Status {
+		case "created", "deleted":
+			metrics.ObserveDelivery(d.GVR, "sent")
+			w.complete(d.key(), d)
+		case "invalid", "forbidden":
+			slog.Error("collector rejected claim, dropping", "claimRef", d.ClaimRef, "status", r.Status, "error", r.Error)
+			metrics.ObserveDelivery(d.GVR, "dropped")
+			w.complete(d.key(), d)
+		default:
+			metrics.ObserveDelivery(d.GVR, "failed")
+			w.retry(d.key(), fmt.Errorf("batch item %s: %s", r.Status, r.Error))
+		}
+	}
+	return true
+}
+
+// rejected reports whether err is a response of the collector rejecting the
+// request for good.
+func rejected(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.permanent()
+}
+
+func (w *ClaimWatcher) pendingDelivery(key string) (*delivery, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d, ok := w.pending[key]
+	return d, ok
+}
+
+// retry spools the pending state of key and requeues key with exponential
+// backoff.
+func (w *ClaimWatcher) retry(key string, err error) {
+	slog.Error("deliver claim, retrying", "claim", key, "attempt", w.queue.NumRequeues(key)+1, "error", err)
+	w.spool(key)
+	w.queue.AddRateLimited(key)
+}
+
+// spool writes the pending state of key to the outbox unless it is there
+// already. Only the worker holding key and runWorkers after the workers have
+// stopped call it, so the states of one claim are spooled in order.
+func (w *ClaimWatcher) spool(key string) {
+	if w.outbox == nil {
+		return
+	}
+	w.mu.Lock()
+	d, ok := w.pending[key]
+	if !ok || d.spooled {
+		w.mu.Unlock()
+		return
+	}
+	d.spooled = true
+	w.mu.Unlock()
+
+	// The outbox is written outside w.mu so a slow disk does not stall
+	// the event handlers.
+	if err := w.outbox.Save(d); err != nil {
+		slog.Error("spool to outbox", "claimRef", d.ClaimRef, "error", err)
+	}
+}
+
+// spoolPending spools the states of all claims still pending.
+func (w *ClaimWatcher) spoolPending() {
+	if w.outbox == nil {
+		return
+	}
+	w.mu.Lock()
+	keys := make([]string, 0, len(w.pending))
+	for key := range w.pending {
+		keys = append(keys, key)
+	}
+	w.mu.Unlock()
+
+	for _, key := range keys {
+		w.spool(key)
+	}
+	if len(keys) > 0 {
+		slog.Info("spooled pending claims to outbox", "claims", len(keys))
+	}
+}
+
+// complete drops the delivered state d of key. A newer state that arrived
+// during delivery is kept; the queue hands the key out again once Done is
+// called.
+func (w *ClaimWatcher) complete(key string, d *delivery) {
+	w.mu.Lock()
+	delivered := w.pending[key] == d
+	if delivered {
+		delete(w.pending, key)
+	}
+	w.mu.Unlock()
+
+	// A spooled record of key is never newer than the pending state, so it
+	// can go once that state is delivered.
+	if delivered && w.outbox != nil {
+		if err := w.outbox.Remove(key); err != nil {
+			slog.Error("remove from outbox", "claim", key, "error", err)
+		}
+	}
+	w.queue.Forget(key)
+}
+
+// deliver sends d in a span continuing the trace of its informer event.
+func (w *ClaimWatcher) deliver(d *delivery) (err error) {
+	name := "ClaimWatcher.sendStatus"
+	if d.Deleted {
+		name = "ClaimWatcher.sendDeletion"
+	}
+	ctx, span := tracing.Tracer().Start(tracing.ContextWithTraceParent(context.Background(), d.TraceParent), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gvr", d.GVR),
+			attribute.String("claim.ref", d.ClaimRef),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if d.Deleted {
+		return w.deleteStatus(ctx, d.Kind, d.ClaimRef)
+	}
+	return w.postStatus(ctx, d.Payload)
+}
+
+func claimRefOf(claim *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", claim.GetNamespace(), claim.GetName())
+}
@@ -0,0 +1,323 @@
+package informer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func newQueueTestClaim(message string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "my-db",
+				"namespace": "default",
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":    "Ready",
+						"status":  "True",
+						"message": message,
+					},
+				},
+			},
+		},
+	}
+}
+
+const testGVR = "example.org/v1/postgresqls"
+
+func newQueueTestWatcher(url string) *ClaimWatcher {
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+	w := NewClaimWatcher(nil, url, "cluster-01", []schema.GroupVersionResource{gvr}, "default")
+	// Keep retries fast in tests.
+	w.queue = workqueue.NewTypedRateLimitingQueue(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](time.Millisecond, 10*time.Millisecond),
+	)
+	return w
+}
+
+func TestQueue_RetriesUntilDelivered(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		received statusPayload
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	w := newQueueTestWatcher(ts.URL)
+	w.enqueue(testGVR, newQueueTestClaim("Resource is available"), false)
+
+	for i := 0; i < 3; i++ {
+		w.processNextItem()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Fatalf("expected 3 delivery attempts, got %d", attempts)
+	}
+	if received.StatusMessage != "Resource is available" {
+		t.Errorf("expected delivered status 'Resource is available', got %q", received.StatusMessage)
+	}
+	if len(w.pending) != 0 {
+		t.Errorf("expected no pending deliveries, got %d", len(w.pending))
+	}
+	if w.queue.Len() != 0 {
+		t.Errorf("expected empty queue, got %d items", w.queue.Len())
+	}
+}
+
+func TestQueue_DropsRejectedClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		dropped bool
+	}{
+		{"bad request", http.StatusBadRequest, true},
+		{"forbidden", http.StatusForbidden, true},
+		{"too large", http.StatusRequestEntityTooLarge, true},
+		{"unauthorized", http.StatusUnauthorized, false},
+		{"not found", http.StatusNotFound, false},
+		{"request timeout", http.StatusRequestTimeout, false},
+		{"too many requests", http.StatusTooManyRequests, false},
+		{"server error", http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+			}))
+			defer ts.Close()
+
+			w := newQueueTestWatcher(ts.URL)
+			w.enqueue(testGVR, newQueueTestClaim("Resource is available"), false)
+			w.processNextItem()
+
+			if dropped := len(w.pending) == 0; dropped != tt.dropped {
+				t.Errorf("expected dropped=%v, got %d pending deliveries", tt.dropped, len(w.pending))
+			}
+			if requeues := w.queue.NumRequeues(testGVR + "/default/my-db"); (requeues == 0) != tt.dropped {
+				t.Errorf("expected dropped=%v, got %d requeues", tt.dropped, requeues)
+			}
+		})
+	}
+}
+
+func TestQueue_SendsLatestState(t *testing.T) {
+	var received []statusPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p statusPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		received = append(received, p)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	w := newQueueTestWatcher(ts.URL)
+	w.enqueue(testGVR, newQueueTestClaim("Creating"), false)
+	w.enqueue(testGVR, newQueueTestClaim("Resource is available"), false)
+
+	if w.queue.Len() != 1 {
+		t.Fatalf("expected updates for one claimRef to be coalesced, got %d queued", w.queue.Len())
+	}
+	w.processNextItem()
+
+	if len(received) != 1 || received[0].StatusMessage != "Resource is available" {
+		t.Fatalf("expected only the latest state to be sent, got %+v", received)
+	}
+}
+
+func TestQueue_Batch(t *testing.T) {
+	var (
+		requests int
+		items    []batchPayloadItem
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/status:batch" {
+			t.Errorf("expected path /api/v1/status:batch, got %q", r.URL.Path)
+		}
+		requests++
+		items = nil
+		json.NewDecoder(r.Body).Decode(&items)
+
+		// Reject the first item as invalid and fail the second one.
+		results := []batchResult{{Index: 0, Status: "invalid"}, {Index: 1, Status: "failed"}}
+		for i := 2; i < len(items); i++ {
+			status := "created"
+			if items[i].Deleted {
+				status = "deleted"
+			}
+			results = append(results, batchResult{Index: i, Status: status})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	defer ts.Close()
+
+	w := newQueueTestWatcher(ts.URL)
+	w.batchWindow = time.Millisecond
+
+	claim := func(name string) *unstructured.Unstructured {
+		c := newQueueTestClaim("Available")
+		c.SetName(name)
+		return c
+	}
+	w.enqueue(testGVR, claim("invalid"), false)
+	w.enqueue(testGVR, claim("failing"), false)
+	w.enqueue(testGVR, claim("ok"), false)
+	w.enqueue(testGVR, claim("gone"), true)
+
+	w.processNextBatch()
+
+	if requests != 1 || len(items) != 4 {
+		t.Fatalf("expected one request with 4 items, got %d requests, %d items", requests, len(items))
+	}
+	if !items[3].Deleted || items[3].ClaimRef != "default/gone" || items[3].Cluster != "cluster-01" {
+		t.Errorf("unexpected deletion item: %+v", items[3])
+	}
+
+	w.mu.Lock()
+	_, failingPending := w.pending[testGVR+"/default/failing"]
+	pending := len(w.pending)
+	w.mu.Unlock()
+	if !failingPending || pending != 1 {
+		t.Fatalf("expected only the failed item to stay pending, got %d pending", pending)
+	}
+
+	// The failed item is retried on its own after the backoff.
+	w.processNextBatch()
+	if requests != 2 || len(items) != 1 || items[0].ClaimRef != "default/failing" {
+		t.Fatalf("expected a retry of default/failing, got %d requests with %+v", requests, items)
+	}
+}
+
+func TestQueue_BatchRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		dropped bool
+	}{
+		{"bad request", http.StatusBadRequest, true},
+		{"unauthorized", http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+			}))
+			defer ts.Close()
+
+			w := newQueueTestWatcher(ts.URL)
+			w.batchWindow = time.Millisecond
+			w.enqueue(testGVR, newQueueTestClaim("Available"), false)
+			w.processNextBatch()
+
+			if dropped := w.PendingDeliveries() == 0; dropped != tt.dropped {
+				t.Errorf("expected dropped=%v, got %d pending deliveries", tt.dropped, w.PendingDeliveries())
+			}
+		})
+	}
+}
+
+func TestQueue_KeysByResource(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	w := newQueueTestWatcher(ts.URL)
+
+	// A claim of another kind with the same namespace and name must not
+	// replace the pending state of the first one.
+	db := newQueueTestClaim("Available")
+	db.SetKind("PostgreSQL")
+	bucket := newQueueTestClaim("Available")
+	bucket.SetKind("Bucket")
+	w.enqueue(testGVR, db, true)
+	w.enqueue("example.org/v1/buckets", bucket, true)
+
+	if w.queue.Len() != 2 {
+		t.Fatalf("expected both claims to be queued, got %d", w.queue.Len())
+	}
+	w.processNextItem()
+	w.processNextItem()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"/api/v1/status/cluster-01/default/my-db?kind=PostgreSQL",
+		"/api/v1/status/cluster-01/default/my-db?kind=Bucket",
+	}
+	if len(received) != 2 || received[0] != want[0] || received[1] != want[1] {
+		t.Fatalf("expected deletions %v, got %v", want, received)
+	}
+}
+
+func TestQueue_PropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	var traceParent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	w := newQueueTestWatcher(ts.URL)
+	w.enqueue(testGVR, newQueueTestClaim("Resource is available"), false)
+	w.processNextItem()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	event, send := spans["ClaimWatcher.event"], spans["ClaimWatcher.sendStatus"]
+	if event == nil || send == nil {
+		t.Fatalf("expected event and send spans, got %v", spans)
+	}
+	if send.Parent().SpanID() != event.SpanContext().SpanID() {
+		t.Errorf("expected send span to continue the event span")
+	}
+	if !strings.Contains(traceParent, send.SpanContext().TraceID().String()) ||
+		!strings.Contains(traceParent, send.SpanContext().SpanID().String()) {
+		t.Errorf("expected traceparent of the send span, got %q", traceParent)
+	}
+}
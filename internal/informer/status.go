@@ -37,3 +37,50 @@ func ExtractClaimStatus(obj *unstructured.Unstructured) (string, error) {
 
 	return "no Ready condition found", nil
 }
+
+// Condition is a single entry of a claim's .status.conditions as reported to
+// the collector.
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// ExtractClaimConditions returns all entries of .status.conditions on an
+// unstructured Crossplane claim. Entries without a type are skipped. A claim
+// without conditions yields an empty slice.
+func ExtractClaimConditions(obj *unstructured.Unstructured) ([]Condition, error) {
+	raw, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return nil, fmt.Errorf("read status.conditions: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	conditions := make([]Condition, 0, len(raw))
+	for _, c := range raw {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condType, _, _ := unstructured.NestedString(cond, "type")
+		if condType == "" {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(cond, "status")
+		reason, _, _ := unstructured.NestedString(cond, "reason")
+		msg, _, _ := unstructured.NestedString(cond, "message")
+		transition, _, _ := unstructured.NestedString(cond, "lastTransitionTime")
+		conditions = append(conditions, Condition{
+			Type:               condType,
+			Status:             status,
+			Reason:             reason,
+			Message:            msg,
+			LastTransitionTime: transition,
+		})
+	}
+	return conditions, nil
+}
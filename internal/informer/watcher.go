@@ -5,113 +5,382 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/stuttgart-things/machinery-status-collector/internal/metrics"
+	"github.com/stuttgart-things/machinery-status-collector/internal/tracing"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
-// ClaimWatcher watches Crossplane claim resources via a dynamic informer and
+// ClaimWatcher watches Crossplane claim resources via dynamic informers and
 // POSTs status updates to the central collector API.
 type ClaimWatcher struct {
 	dynamicClient dynamic.Interface
 	collectorURL  string
 	clusterName   string
-	gvr           schema.GroupVersionResource
+	gvrs          []schema.GroupVersionResource
 	namespace     string
 	httpClient    *http.Client
+	discoverXRDs  bool
+	queue         workqueue.TypedRateLimitingInterface[string]
+	outbox        *Outbox
+	batchWindow   time.Duration
+	synced        atomic.Bool
+
+	mu sync.Mutex
+	// pending holds the latest undelivered state per claimRef.
+	pending map[string]*delivery
+	// running holds the informers started by AddGVR.
+	running map[schema.GroupVersionResource]*claimInformer
+	// xrdGVRs maps XRD names to the claim GVR they offer.
+	xrdGVRs map[string]schema.GroupVersionResource
+}
+
+// ClaimWatcherOption configures optional ClaimWatcher behaviour.
+type ClaimWatcherOption func(*ClaimWatcher)
+
+// WithXRDDiscovery additionally watches Crossplane CompositeResourceDefinitions
+// and watches the claim kinds they offer as they are created and deleted.
+func WithXRDDiscovery(enabled bool) ClaimWatcherOption {
+	return func(w *ClaimWatcher) {
+		w.discoverXRDs = enabled
+	}
+}
+
+// WithOutbox spools undelivered claim states to o so they survive restarts.
+func WithOutbox(o *Outbox) ClaimWatcherOption {
+	return func(w *ClaimWatcher) {
+		w.outbox = o
+	}
+}
+
+// WithBatchWindow coalesces claim updates arriving within window and sends
+// them to the collector's batch endpoint. A zero window sends every claim in
+// its own request.
+func WithBatchWindow(window time.Duration) ClaimWatcherOption {
+	return func(w *ClaimWatcher) {
+		w.batchWindow = window
+	}
 }
 
-// NewClaimWatcher creates a ClaimWatcher for the given Crossplane claim GVR.
-func NewClaimWatcher(dynamicClient dynamic.Interface, collectorURL, clusterName string, gvr schema.GroupVersionResource, namespace string) *ClaimWatcher {
-	return &ClaimWatcher{
+// NewClaimWatcher creates a ClaimWatcher for the given Crossplane claim GVRs.
+func NewClaimWatcher(dynamicClient dynamic.Interface, collectorURL, clusterName string, gvrs []schema.GroupVersionResource, namespace string, opts ...ClaimWatcherOption) *ClaimWatcher {
+	w := &ClaimWatcher{
 		dynamicClient: dynamicClient,
 		collectorURL:  collectorURL,
 		clusterName:   clusterName,
-		gvr:           gvr,
+		gvrs:          gvrs,
 		namespace:     namespace,
 		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		queue:         newSendQueue(),
+		pending:       make(map[string]*delivery),
+		running:       make(map[schema.GroupVersionResource]*claimInformer),
+		xrdGVRs:       make(map[string]schema.GroupVersionResource),
+	}
+	for _, opt := range opts {
+		opt(w)
 	}
+	return w
 }
 
-// Start begins watching the configured GVR and blocks until ctx is cancelled.
+// Start begins watching the configured GVRs through one shared informer
+// factory and blocks until ctx is cancelled. Event handlers only enqueue
+// claims; delivery to the collector happens in background workers. After
+// cancellation Start returns once the workers have stopped and the claims
+// still pending have been spooled to the outbox.
 func (w *ClaimWatcher) Start(ctx context.Context) error {
+	if w.outbox != nil {
+		if err := w.replayOutbox(); err != nil {
+			return fmt.Errorf("replay outbox: %w", err)
+		}
+	}
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		w.runWorkers(ctx)
+	}()
+
 	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(
 		w.dynamicClient, 0, w.namespace, nil,
 	)
 
-	informer := factory.ForResource(w.gvr).Informer()
+	for _, gvr := range w.gvrs {
+		informer := factory.ForResource(gvr).Informer()
+		if _, err := informer.AddEventHandler(w.eventHandler(gvr)); err != nil {
+			return fmt.Errorf("add event handler for %s: %w", gvr, err)
+		}
+	}
+
+	factory.Start(ctx.Done())
+	for gvr, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("sync informer cache for %s: %w", gvr, ctx.Err())
+		}
+	}
+
+	if w.discoverXRDs {
+		if err := w.startXRDDiscovery(ctx); err != nil {
+			return err
+		}
+	}
+	w.synced.Store(true)
+
+	<-ctx.Done()
+	<-workersDone
+	return ctx.Err()
+}
+
+// Ready reports whether the informer caches of the configured GVRs have
+// synced. With XRD discovery, the XRD cache and the claim informers started
+// for the XRDs found initially must have synced as well.
+func (w *ClaimWatcher) Ready() bool {
+	return w.synced.Load()
+}
+
+// PendingDeliveries returns the number of claims waiting for delivery,
+// including those backing off after a failure.
+func (w *ClaimWatcher) PendingDeliveries() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
 
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+func (w *ClaimWatcher) eventHandler(gvr schema.GroupVersionResource) cache.ResourceEventHandler {
+	label := gvrLabel(gvr)
+	return cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			u, ok := obj.(*unstructured.Unstructured)
 			if !ok {
 				return
 			}
-			if err := w.sendStatus(u); err != nil {
-				slog.Error("send status on add", "error", err)
-			}
+			metrics.ObserveClaimEvent(label, "add")
+			w.enqueue(label, u, false)
 		},
 		UpdateFunc: func(_, newObj interface{}) {
 			u, ok := newObj.(*unstructured.Unstructured)
 			if !ok {
 				return
 			}
-			if err := w.sendStatus(u); err != nil {
-				slog.Error("send status on update", "error", err)
-			}
+			metrics.ObserveClaimEvent(label, "update")
+			w.enqueue(label, u, false)
 		},
-	})
-
-	factory.Start(ctx.Done())
-	factory.WaitForCacheSync(ctx.Done())
+		DeleteFunc: func(obj interface{}) {
+			metrics.ObserveClaimEvent(label, "delete")
+			w.onDelete(label, obj)
+		},
+	}
+}
 
-	<-ctx.Done()
-	return ctx.Err()
+// onDelete reports a deleted claim. When the watch missed the delete event the
+// informer hands over a DeletedFinalStateUnknown tombstone wrapping the last
+// known object instead of the object itself.
+func (w *ClaimWatcher) onDelete(gvr string, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		slog.Error("unexpected object on delete", "type", fmt.Sprintf("%T", obj))
+		return
+	}
+	w.enqueue(gvr, u, true)
 }
 
 type statusPayload struct {
-	Cluster       string `json:"cluster"`
-	ClaimRef      string `json:"claimRef"`
-	StatusMessage string `json:"statusMessage"`
+	Cluster       string      `json:"cluster"`
+	ClaimRef      string      `json:"claimRef"`
+	Kind          string      `json:"kind,omitempty"`
+	StatusMessage string      `json:"statusMessage"`
+	Conditions    []Condition `json:"conditions,omitempty"`
 }
 
-// sendStatus extracts the claim status and POSTs it to the collector API.
-func (w *ClaimWatcher) sendStatus(claim *unstructured.Unstructured) error {
+// buildPayload extracts the claim status into the payload sent to the
+// collector API.
+func (w *ClaimWatcher) buildPayload(claim *unstructured.Unstructured) (*statusPayload, error) {
 	statusMsg, err := ExtractClaimStatus(claim)
 	if err != nil {
-		return fmt.Errorf("extract status: %w", err)
+		return nil, fmt.Errorf("extract status: %w", err)
+	}
+	conditions, err := ExtractClaimConditions(claim)
+	if err != nil {
+		return nil, fmt.Errorf("extract conditions: %w", err)
 	}
 
-	claimRef := fmt.Sprintf("%s/%s", claim.GetNamespace(), claim.GetName())
-
-	payload := statusPayload{
+	return &statusPayload{
 		Cluster:       w.clusterName,
-		ClaimRef:      claimRef,
+		ClaimRef:      claimRefOf(claim),
+		Kind:          claim.GetKind(),
 		StatusMessage: statusMsg,
+		Conditions:    conditions,
+	}, nil
+}
+
+// statusError reports an unexpected HTTP status code from the collector API.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
+
+// permanent reports whether the collector rejected the request for good, so
+// that sending it again cannot succeed. Other client errors may pass: 401 is
+// also returned for clock skew or a token not yet rolled out, and 404 by
+// collectors without the route.
+func (e *statusError) permanent() bool {
+	switch e.code {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusRequestEntityTooLarge:
+		return true
 	}
+	return false
+}
 
+// postStatus POSTs a status payload to the collector API.
+func (w *ClaimWatcher) postStatus(ctx context.Context, payload *statusPayload) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("marshal payload: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/api/v1/status", w.collectorURL)
-	resp, err := w.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	endpoint := fmt.Sprintf("%s/api/v1/status", w.collectorURL)
+	req, err := w.newRequest(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return err
+	}
+	resp, err := w.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("post status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return &statusError{code: resp.StatusCode}
 	}
 
-	slog.Info("status sent", "cluster", w.clusterName, "claimRef", claimRef, "status", statusMsg)
+	slog.Info("status sent", "cluster", w.clusterName, "kind", payload.Kind, "claimRef", payload.ClaimRef, "status", payload.StatusMessage)
 	return nil
 }
+
+type batchPayloadItem struct {
+	statusPayload
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+type batchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// postBatch sends a batch of deliveries to the collector API and returns the
+// per-item results in the order of batch.
+func (w *ClaimWatcher) postBatch(ctx context.Context, batch []*delivery) ([]batchResult, error) {
+	items := make([]batchPayloadItem, 0, len(batch))
+	for _, d := range batch {
+		if d.Deleted {
+			items = append(items, batchPayloadItem{
+				statusPayload: statusPayload{Cluster: w.clusterName, ClaimRef: d.ClaimRef, Kind: d.Kind},
+				Deleted:       true,
+			})
+			continue
+		}
+		items = append(items, batchPayloadItem{statusPayload: *d.Payload})
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/status:batch", w.collectorURL)
+	req, err := w.newRequest(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("post batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	var result struct {
+		Results []batchResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode batch response: %w", err)
+	}
+	if len(result.Results) != len(items) {
+		return nil, fmt.Errorf("batch response has %d results for %d items", len(result.Results), len(items))
+	}
+
+	ordered := make([]batchResult, len(items))
+	for _, r := range result.Results {
+		if r.Index < 0 || r.Index >= len(items) {
+			return nil, fmt.Errorf("batch response has out-of-range index %d", r.Index)
+		}
+		ordered[r.Index] = r
+	}
+
+	slog.Info("batch sent", "cluster", w.clusterName, "items", len(items))
+	return ordered, nil
+}
+
+// deleteStatus reports the deletion of claimRef, a claim of the given kind,
+// to the collector API.
+func (w *ClaimWatcher) deleteStatus(ctx context.Context, kind, claimRef string) error {
+	endpoint := fmt.Sprintf("%s/api/v1/status/%s/%s", w.collectorURL, url.PathEscape(w.clusterName), claimRef)
+	if kind != "" {
+		endpoint += "?kind=" + url.QueryEscape(kind)
+	}
+	req, err := w.newRequest(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{code: resp.StatusCode}
+	}
+
+	slog.Info("deletion sent", "cluster", w.clusterName, "kind", kind, "claimRef", claimRef)
+	return nil
+}
+
+// newRequest builds a request to the collector API that carries the trace
+// context of ctx.
+func (w *ClaimWatcher) newRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Request, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	tracing.Inject(ctx, req.Header)
+	return req, nil
+}
@@ -1,13 +1,22 @@
 package informer
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/stuttgart-things/machinery-status-collector/internal/signing"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestExtractClaimStatus_ReadyCondition(t *testing.T) {
@@ -122,7 +131,53 @@ func TestExtractClaimStatus_NoReadyCondition(t *testing.T) {
 	}
 }
 
-func TestSendStatus_RequestFormat(t *testing.T) {
+func TestExtractClaimConditions(t *testing.T) {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{
+						"type":               "Synced",
+						"status":             "True",
+						"reason":             "ReconcileSuccess",
+						"lastTransitionTime": "2026-01-01T00:00:00Z",
+					},
+					map[string]interface{}{
+						"type":    "Ready",
+						"status":  "False",
+						"reason":  "Creating",
+						"message": "waiting for database",
+					},
+					map[string]interface{}{
+						"status": "True",
+					},
+				},
+			},
+		},
+	}
+
+	conditions, err := ExtractClaimConditions(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Condition{
+		{Type: "Synced", Status: "True", Reason: "ReconcileSuccess", LastTransitionTime: "2026-01-01T00:00:00Z"},
+		{Type: "Ready", Status: "False", Reason: "Creating", Message: "waiting for database"},
+	}
+	if !reflect.DeepEqual(conditions, want) {
+		t.Errorf("expected %+v, got %+v", want, conditions)
+	}
+
+	conditions, err = ExtractClaimConditions(&unstructured.Unstructured{Object: map[string]interface{}{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conditions) != 0 {
+		t.Errorf("expected no conditions, got %+v", conditions)
+	}
+}
+
+func TestDeliver_RequestFormat(t *testing.T) {
 	var received statusPayload
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -142,7 +197,7 @@ func TestSendStatus_RequestFormat(t *testing.T) {
 	defer ts.Close()
 
 	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
-	w := NewClaimWatcher(nil, ts.URL, "cluster-01", gvr, "default")
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default")
 
 	claim := &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -164,9 +219,8 @@ func TestSendStatus_RequestFormat(t *testing.T) {
 		},
 	}
 
-	if err := w.sendStatus(claim); err != nil {
-		t.Fatalf("sendStatus failed: %v", err)
-	}
+	w.enqueue(testGVR, claim, false)
+	w.processNextItem()
 
 	if received.Cluster != "cluster-01" {
 		t.Errorf("expected cluster 'cluster-01', got %q", received.Cluster)
@@ -174,19 +228,25 @@ func TestSendStatus_RequestFormat(t *testing.T) {
 	if received.ClaimRef != "default/my-db" {
 		t.Errorf("expected claimRef 'default/my-db', got %q", received.ClaimRef)
 	}
+	if received.Kind != "PostgreSQL" {
+		t.Errorf("expected kind 'PostgreSQL', got %q", received.Kind)
+	}
 	if received.StatusMessage != "Resource is available" {
 		t.Errorf("expected statusMessage 'Resource is available', got %q", received.StatusMessage)
 	}
+	if len(received.Conditions) != 1 || received.Conditions[0].Type != "Ready" {
+		t.Errorf("expected the Ready condition in the payload, got %+v", received.Conditions)
+	}
 }
 
-func TestSendStatus_ServerError(t *testing.T) {
+func TestDeliver_ServerError(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer ts.Close()
 
 	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
-	w := NewClaimWatcher(nil, ts.URL, "cluster-01", gvr, "default")
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default")
 
 	claim := &unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -206,8 +266,187 @@ func TestSendStatus_ServerError(t *testing.T) {
 		},
 	}
 
-	err := w.sendStatus(claim)
-	if err == nil {
+	payload, err := w.buildPayload(claim)
+	if err != nil {
+		t.Fatalf("build payload: %v", err)
+	}
+	if err := w.deliver(&delivery{ClaimRef: "default/my-db", GVR: testGVR, Payload: payload}); err == nil {
 		t.Fatal("expected error for server error response")
 	}
 }
+
+func TestOnDelete_Tombstone(t *testing.T) {
+	var method, path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default")
+
+	claim := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "my-db",
+				"namespace": "default",
+			},
+		},
+	}
+
+	w.onDelete(testGVR, cache.DeletedFinalStateUnknown{Key: "default/my-db", Obj: claim})
+	w.processNextItem()
+
+	if method != http.MethodDelete {
+		t.Errorf("expected DELETE, got %q", method)
+	}
+	if path != "/api/v1/status/cluster-01/default/my-db" {
+		t.Errorf("expected path /api/v1/status/cluster-01/default/my-db, got %q", path)
+	}
+}
+
+func TestDeliver_DeletionServerError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default")
+
+	if err := w.deliver(&delivery{ClaimRef: "default/my-db", GVR: testGVR, Kind: "PostgreSQL", Deleted: true}); err == nil {
+		t.Fatal("expected error for server error response")
+	}
+}
+
+func TestParseGVRList(t *testing.T) {
+	gvrs, err := ParseGVRList("database.example.org/v1alpha1/postgresqls, storage.example.org/v1/buckets,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []schema.GroupVersionResource{
+		{Group: "database.example.org", Version: "v1alpha1", Resource: "postgresqls"},
+		{Group: "storage.example.org", Version: "v1", Resource: "buckets"},
+	}
+	if !reflect.DeepEqual(gvrs, want) {
+		t.Errorf("expected %v, got %v", want, gvrs)
+	}
+
+	for _, bad := range []string{"postgresqls", "example.org/v1", "example.org//postgresqls", "a/b/c/d"} {
+		if _, err := ParseGVRList(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLoadGVRFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gvrs.yaml")
+	content := `gvrs:
+  - group: database.example.org
+    version: v1alpha1
+    resource: postgresqls
+  - group: storage.example.org
+    version: v1
+    resource: buckets
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	gvrs, err := LoadGVRFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gvrs) != 2 || gvrs[1].Resource != "buckets" {
+		t.Errorf("unexpected GVRs: %v", gvrs)
+	}
+}
+
+func TestSendStatus_BearerToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("write token file: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		opt  ClaimWatcherOption
+		want string
+	}{
+		{"static token", WithBearerToken("env-token"), "Bearer env-token"},
+		{"token file", WithBearerTokenFile(tokenFile), "Bearer file-token"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer ts.Close()
+
+			gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+			w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default", tc.opt)
+
+			if err := w.deleteStatus(context.Background(), "PostgreSQL", "default/my-db"); err != nil {
+				t.Fatalf("deleteStatus failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected Authorization %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSendStatus_TLSConfigWithBearerToken(t *testing.T) {
+	var got string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default",
+		WithBearerToken("env-token"),
+		WithTLSConfig(&tls.Config{RootCAs: roots}),
+	)
+
+	if err := w.deleteStatus(context.Background(), "PostgreSQL", "default/my-db"); err != nil {
+		t.Fatalf("deleteStatus failed: %v", err)
+	}
+	if got != "Bearer env-token" {
+		t.Errorf("expected bearer token over TLS, got %q", got)
+	}
+}
+
+func TestSendStatus_RequestSigning(t *testing.T) {
+	secret := []byte("s3cr3t")
+	var valid bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		valid = signing.Verify(secret, r.Method, r.URL.RequestURI(),
+			r.Header.Get(signing.HeaderTimestamp), r.Header.Get(signing.HeaderNonce), body,
+			r.Header.Get(signing.HeaderSignature))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	gvr := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "postgresqls"}
+	w := NewClaimWatcher(nil, ts.URL, "cluster-01", []schema.GroupVersionResource{gvr}, "default",
+		WithRequestSigning(secret),
+		WithBearerToken("env-token"),
+	)
+
+	payload := &statusPayload{Cluster: "cluster-01", ClaimRef: "default/my-db", StatusMessage: "ready"}
+	if err := w.postStatus(context.Background(), payload); err != nil {
+		t.Fatalf("postStatus failed: %v", err)
+	}
+	if !valid {
+		t.Error("expected request to carry a valid signature")
+	}
+}
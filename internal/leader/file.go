@@ -0,0 +1,65 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// FileElector elects a leader among processes on one host through an
+// exclusive lock on a file. The lock is released by the operating system
+// when the process exits, so it needs no expiry.
+type FileElector struct {
+	path   string
+	leader atomic.Bool
+}
+
+// NewFileElector competes for the lock on path, creating the file if needed.
+func NewFileElector(path string) *FileElector {
+	return &FileElector{path: path}
+}
+
+// Run implements Elector. Once acquired, the lock is held until ctx is
+// cancelled.
+func (e *FileElector) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	f, err := os.OpenFile(e.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open lock file: %w", err)
+	}
+	defer f.Close()
+
+	ticker := time.NewTicker(retryPeriod)
+	defer ticker.Stop()
+	for {
+		err := tryLock(f)
+		if err == nil {
+			break
+		}
+		if err != errLocked {
+			return fmt.Errorf("lock %s: %w", e.path, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+	defer unlock(f)
+
+	log.Printf("acquired lock %s", e.path)
+	e.leader.Store(true)
+	defer e.leader.Store(false)
+	lead(ctx)
+	return nil
+}
+
+// IsLeader implements Elector.
+func (e *FileElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+var errLocked = errors.New("locked by another process")
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package leader
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLock(f *os.File) error {
+	return errors.New("file locks are not supported on this platform")
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
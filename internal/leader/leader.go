@@ -0,0 +1,97 @@
+// Package leader elects a single collector replica to run the reconciler.
+// In Kubernetes the replicas compete for a coordination.k8s.io Lease; for
+// local use an exclusive lock on a file serves the same purpose.
+package leader
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Elector runs a function only while this process holds leadership.
+type Elector interface {
+	// Run campaigns for leadership until ctx is cancelled and calls lead each
+	// time leadership is acquired. The context passed to lead is cancelled
+	// when leadership is lost. Run returns nil once ctx is cancelled, or an
+	// error if it cannot campaign at all.
+	Run(ctx context.Context, lead func(ctx context.Context)) error
+	// IsLeader reports whether this process currently holds leadership.
+	IsLeader() bool
+}
+
+// Timings of the Lease election, matching the defaults of Kubernetes
+// controllers.
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// LeaseElector elects a leader through a Kubernetes Lease.
+type LeaseElector struct {
+	lock   *resourcelock.LeaseLock
+	leader atomic.Bool
+	// leading is held while lead runs, so a new term cannot start before
+	// the previous one has wound down.
+	leading sync.Mutex
+}
+
+// NewLeaseElector competes for the Lease name in namespace under identity,
+// which must be unique per replica, e.g. the pod name.
+func NewLeaseElector(client kubernetes.Interface, namespace, name, identity string) *LeaseElector {
+	return &LeaseElector{
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+	}
+}
+
+// Run implements Elector. After losing the Lease it campaigns again.
+func (e *LeaseElector) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            e.lock,
+			LeaseDuration:   leaseDuration,
+			RenewDeadline:   renewDeadline,
+			RetryPeriod:     retryPeriod,
+			ReleaseOnCancel: true,
+			Name:            e.lock.LeaseMeta.Name,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					log.Printf("acquired lease %s/%s as %s", e.lock.LeaseMeta.Namespace, e.lock.LeaseMeta.Name, e.lock.Identity())
+					e.leading.Lock()
+					defer e.leading.Unlock()
+					e.leader.Store(true)
+					lead(ctx)
+				},
+				OnStoppedLeading: func() {
+					e.leader.Store(false)
+					log.Printf("released lease %s/%s", e.lock.LeaseMeta.Namespace, e.lock.LeaseMeta.Name)
+				},
+				OnNewLeader: func(identity string) {
+					if identity != e.lock.Identity() {
+						log.Printf("current leader is %s", identity)
+					}
+				},
+			},
+		})
+		e.leading.Lock()
+		e.leading.Unlock()
+	}
+	return nil
+}
+
+// IsLeader implements Elector.
+func (e *LeaseElector) IsLeader() bool {
+	return e.leader.Load()
+}
@@ -0,0 +1,98 @@
+package leader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFileElector_SingleLeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.lock")
+	first, second := NewFileElector(path), NewFileElector(path)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	leading1 := make(chan struct{})
+	done1 := make(chan struct{})
+	go func() {
+		defer close(done1)
+		first.Run(ctx1, func(ctx context.Context) {
+			close(leading1)
+			<-ctx.Done()
+		})
+	}()
+	<-leading1
+	if !first.IsLeader() {
+		t.Fatal("expected first elector to lead")
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	leading2 := make(chan struct{})
+	go second.Run(ctx2, func(ctx context.Context) {
+		close(leading2)
+		<-ctx.Done()
+	})
+
+	select {
+	case <-leading2:
+		t.Fatal("expected second elector to wait while the lock is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel1()
+	<-done1
+	if first.IsLeader() {
+		t.Fatal("expected first elector to step down after cancellation")
+	}
+	select {
+	case <-leading2:
+	case <-time.After(2 * retryPeriod):
+		t.Fatal("expected second elector to take over the released lock")
+	}
+}
+
+func TestFileElector_Error(t *testing.T) {
+	e := NewFileElector(filepath.Join(t.TempDir(), "missing", "collector.lock"))
+
+	err := e.Run(context.Background(), func(ctx context.Context) {
+		t.Fatal("expected not to lead without a lock file")
+	})
+	if err == nil {
+		t.Fatal("expected an error when the lock file cannot be opened")
+	}
+}
+
+func TestLeaseElector_Leads(t *testing.T) {
+	e := NewLeaseElector(fake.NewClientset(), "default", "collector", "pod-a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leading := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, func(ctx context.Context) {
+			close(leading)
+			<-ctx.Done()
+		})
+	}()
+
+	select {
+	case <-leading:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected to acquire the lease")
+	}
+	if !e.IsLeader() {
+		t.Fatal("expected IsLeader to report leadership")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return after cancellation")
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const informerNamespace = "machinery_status_informer"
+
+// InformerRegistry holds the metrics of the informer agent. It is separate
+// from Registry so each subcommand only exports its own metrics.
+var InformerRegistry = prometheus.NewRegistry()
+
+var (
+	claimEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: informerNamespace,
+		Name:      "claim_events_total",
+		Help:      "Claim events seen by the informers, by GVR and event (add, update or delete).",
+	}, []string{"gvr", "event"})
+
+	deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: informerNamespace,
+		Name:      "deliveries_total",
+		Help:      "Claim deliveries to the collector by GVR and outcome (sent, failed or dropped).",
+	}, []string{"gvr", "outcome"})
+
+	deliveryLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: informerNamespace,
+		Name:      "delivery_last_success_timestamp_seconds",
+		Help:      "Unix time of the last claim successfully delivered to the collector, by GVR.",
+	}, []string{"gvr"})
+)
+
+func init() {
+	InformerRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		claimEvents,
+		deliveries,
+		deliveryLastSuccess,
+	)
+}
+
+// InformerHandler serves the metrics in InformerRegistry.
+func InformerHandler() http.Handler {
+	return promhttp.HandlerFor(InformerRegistry, promhttp.HandlerOpts{})
+}
+
+// ObserveClaimEvent records an informer event for a claim of gvr.
+func ObserveClaimEvent(gvr, event string) {
+	claimEvents.WithLabelValues(gvr, event).Inc()
+}
+
+// ObserveDelivery records the outcome of delivering a claim of gvr. A sent
+// delivery also updates the last success time.
+func ObserveDelivery(gvr, outcome string) {
+	deliveries.WithLabelValues(gvr, outcome).Inc()
+	if outcome == "sent" {
+		deliveryLastSuccess.WithLabelValues(gvr).SetToCurrentTime()
+	}
+}
+
+// RegisterDeliveryQueue exports the number of claims waiting for delivery,
+// including those backing off after a failure. depth is called on every
+// scrape.
+func RegisterDeliveryQueue(depth func() int) error {
+	return InformerRegistry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: informerNamespace,
+		Name:      "delivery_queue_depth",
+		Help:      "Claims waiting for delivery to the collector, including retries.",
+	}, func() float64 { return float64(depth()) }))
+}
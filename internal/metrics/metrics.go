@@ -0,0 +1,158 @@
+// Package metrics defines the Prometheus metrics exported by the collector
+// server and the informer agent and serves them from dedicated registries.
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "machinery_status_collector"
+
+// Registry holds the metrics of the collector server, including Go runtime
+// and process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled by the API, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests handled by the API, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of reconcile runs.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	})
+
+	reconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconciles_total",
+		Help:      "Reconcile runs by outcome (success or error).",
+	}, []string{"outcome"})
+
+	reconcileLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reconcile_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful reconcile run.",
+	})
+
+	gitRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "git_requests_total",
+		Help:      "Git provider API requests by provider, method and status code; code is \"error\" if no response was received.",
+	}, []string{"provider", "method", "code"})
+
+	storeWritesRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_writes_rejected_total",
+		Help:      "Writes rejected because the status store is full.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		reconcileDuration,
+		reconciles,
+		reconcileLastSuccess,
+		gitRequests,
+		storeWritesRejected,
+	)
+}
+
+// Handler serves the metrics in Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records a request handled by the API. route is the
+// matched ServeMux pattern, so that path parameters do not create new series.
+func ObserveHTTPRequest(method, route string, code int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveReconcile records the duration and outcome of a reconcile run.
+func ObserveReconcile(duration time.Duration, err error) {
+	reconcileDuration.Observe(duration.Seconds())
+	if err != nil {
+		reconciles.WithLabelValues("error").Inc()
+		return
+	}
+	reconciles.WithLabelValues("success").Inc()
+	reconcileLastSuccess.SetToCurrentTime()
+}
+
+// ObserveGitRequest records an API call to the Git provider, e.g. "github".
+// A zero code means the request failed without a response.
+func ObserveGitRequest(provider, method string, code int) {
+	label := "error"
+	if code != 0 {
+		label = strconv.Itoa(code)
+	}
+	gitRequests.WithLabelValues(provider, method, label).Inc()
+}
+
+// ObserveStoreFull records a write rejected because the status store is
+// full.
+func ObserveStoreFull() {
+	storeWritesRejected.Inc()
+}
+
+// StoreStats returns the number of entries in the status store and how many
+// of them are dirty.
+type StoreStats func() (entries, dirty int, err error)
+
+// RegisterStore exports the size and dirty count of the status store. stats
+// is called on every scrape.
+func RegisterStore(stats StoreStats) error {
+	return Registry.Register(&storeCollector{stats: stats})
+}
+
+var (
+	storeEntriesDesc = prometheus.NewDesc(namespace+"_store_entries",
+		"Status entries held in the store.", nil, nil)
+	storeDirtyDesc = prometheus.NewDesc(namespace+"_store_dirty_entries",
+		"Status entries not yet reconciled into the registry.", nil, nil)
+)
+
+type storeCollector struct {
+	stats StoreStats
+}
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- storeEntriesDesc
+	ch <- storeDirtyDesc
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	entries, dirty, err := c.stats()
+	if err != nil {
+		slog.Error("collect store metrics", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(storeEntriesDesc, prometheus.GaugeValue, float64(entries))
+	ch <- prometheus.MustNewConstMetric(storeDirtyDesc, prometheus.GaugeValue, float64(dirty))
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveReconcile(t *testing.T) {
+	successes := testutil.ToFloat64(reconciles.WithLabelValues("success"))
+	failures := testutil.ToFloat64(reconciles.WithLabelValues("error"))
+
+	ObserveReconcile(time.Second, nil)
+	ObserveReconcile(time.Second, errors.New("boom"))
+
+	if got := testutil.ToFloat64(reconciles.WithLabelValues("success")); got != successes+1 {
+		t.Errorf("expected %v successes, got %v", successes+1, got)
+	}
+	if got := testutil.ToFloat64(reconciles.WithLabelValues("error")); got != failures+1 {
+		t.Errorf("expected %v errors, got %v", failures+1, got)
+	}
+	if testutil.ToFloat64(reconcileLastSuccess) == 0 {
+		t.Error("expected last success timestamp to be set")
+	}
+}
+
+func TestObserveGitRequest(t *testing.T) {
+	ObserveGitRequest("github", "GET", 404)
+	ObserveGitRequest("github", "POST", 0)
+
+	if got := testutil.ToFloat64(gitRequests.WithLabelValues("github", "GET", "404")); got < 1 {
+		t.Errorf("expected GET 404 to be counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(gitRequests.WithLabelValues("github", "POST", "error")); got < 1 {
+		t.Errorf("expected failed POST to be counted as error, got %v", got)
+	}
+}
+
+func TestStoreCollector(t *testing.T) {
+	c := &storeCollector{stats: func() (int, int, error) { return 5, 2, nil }}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	want := `
+# HELP machinery_status_collector_store_dirty_entries Status entries not yet reconciled into the registry.
+# TYPE machinery_status_collector_store_dirty_entries gauge
+machinery_status_collector_store_dirty_entries 2
+# HELP machinery_status_collector_store_entries Status entries held in the store.
+# TYPE machinery_status_collector_store_entries gauge
+machinery_status_collector_store_entries 5
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
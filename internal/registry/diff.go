@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ChangeType describes how a claim differs between two registry files.
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeUpdated ChangeType = "updated"
+	ChangeRemoved ChangeType = "removed"
+)
+
+// ClaimChange is a semantic difference for a single claim.
+type ClaimChange struct {
+	Type     ChangeType
+	Cluster  string
+	Kind     string
+	ClaimRef string
+	Before   *ClaimEntry
+	After    *ClaimEntry
+}
+
+// Diff compares two registry files and returns the semantic changes between
+// them, ignoring LastCheckedAt. Changes are sorted by cluster, claimRef and
+// kind.
+func Diff(before, after *RegistryFile) []ClaimChange {
+	var changes []ClaimChange
+
+	for cluster, claims := range after.Clusters {
+		for i := range claims {
+			a := &claims[i]
+			b := FindClaim(before, cluster, a.Kind, a.ClaimRef)
+			switch {
+			case b == nil:
+				changes = append(changes, ClaimChange{Type: ChangeAdded, Cluster: cluster, Kind: a.Kind, ClaimRef: a.ClaimRef, After: a})
+			case !claimEqual(*b, *a):
+				changes = append(changes, ClaimChange{Type: ChangeUpdated, Cluster: cluster, Kind: a.Kind, ClaimRef: a.ClaimRef, Before: b, After: a})
+			}
+		}
+	}
+
+	for cluster, claims := range before.Clusters {
+		for i := range claims {
+			b := &claims[i]
+			if FindClaim(after, cluster, b.Kind, b.ClaimRef) == nil {
+				changes = append(changes, ClaimChange{Type: ChangeRemoved, Cluster: cluster, Kind: b.Kind, ClaimRef: b.ClaimRef, Before: b})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Cluster != changes[j].Cluster {
+			return changes[i].Cluster < changes[j].Cluster
+		}
+		if changes[i].ClaimRef != changes[j].ClaimRef {
+			return changes[i].ClaimRef < changes[j].ClaimRef
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
+
+// claimEqual compares two entries while ignoring LastCheckedAt.
+func claimEqual(a, b ClaimEntry) bool {
+	a.LastCheckedAt = ""
+	b.LastCheckedAt = ""
+	return reflect.DeepEqual(a, b)
+}
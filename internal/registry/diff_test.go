@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"testing"
+)
+
+func TestDiff_NoChanges(t *testing.T) {
+	before, _ := ParseRegistry([]byte(sampleYAML))
+	after, _ := ParseRegistry([]byte(sampleYAML))
+
+	TouchClaim(after, "cluster-01", "", "postgresqls.2.2.2/my-db")
+
+	if changes := Diff(before, after); len(changes) != 0 {
+		t.Fatalf("expected timestamp-only change to be ignored, got %+v", changes)
+	}
+}
+
+func TestDiff_StatusChange(t *testing.T) {
+	before, _ := ParseRegistry([]byte(sampleYAML))
+	after, _ := ParseRegistry([]byte(sampleYAML))
+
+	UpdateClaimStatus(after, "cluster-02", "", "postgresqls.2.2.2/web-db", "Degraded")
+
+	changes := Diff(before, after)
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(changes))
+	}
+	c := changes[0]
+	if c.Type != ChangeUpdated || c.Cluster != "cluster-02" || c.ClaimRef != "postgresqls.2.2.2/web-db" {
+		t.Fatalf("unexpected change: %+v", c)
+	}
+	if c.Before.StatusMessage != "Ready" || c.After.StatusMessage != "Degraded" {
+		t.Fatalf("unexpected status transition: %q -> %q", c.Before.StatusMessage, c.After.StatusMessage)
+	}
+}
+
+func TestDiff_AddedAndRemoved(t *testing.T) {
+	before, _ := ParseRegistry([]byte(sampleYAML))
+	after, _ := ParseRegistry([]byte(sampleYAML))
+
+	after.Clusters["cluster-03"] = []ClaimEntry{{Name: "new", Namespace: "default", ClaimRef: "default/new"}}
+	delete(after.Clusters, "cluster-02")
+
+	changes := Diff(before, after)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].Type != ChangeRemoved || changes[0].Cluster != "cluster-02" {
+		t.Errorf("expected cluster-02 claim to be removed, got %+v", changes[0])
+	}
+	if changes[1].Type != ChangeAdded || changes[1].Cluster != "cluster-03" {
+		t.Errorf("expected cluster-03 claim to be added, got %+v", changes[1])
+	}
+}
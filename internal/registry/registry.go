@@ -1,6 +1,8 @@
 package registry
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -18,24 +20,168 @@ func ParseRegistry(data []byte) (*RegistryFile, error) {
 	return &reg, nil
 }
 
-// UpdateClaimStatus finds a claim by cluster and claimRef, then updates its
-// StatusMessage and LastCheckedAt. Returns true if a matching entry was found.
-func UpdateClaimStatus(reg *RegistryFile, cluster, claimRef, status string) bool {
-	claims, ok := reg.Clusters[cluster]
-	if !ok {
+// UpdateClaimStatus finds a claim by cluster, kind and claimRef, then updates
+// its StatusMessage. LastCheckedAt is only bumped when the status actually
+// changes. A claim previously marked as deleted is revived. Returns true if a
+// matching entry was found.
+func UpdateClaimStatus(reg *RegistryFile, cluster, kind, claimRef, status string) bool {
+	claim := FindClaim(reg, cluster, kind, claimRef)
+	if claim == nil {
 		return false
 	}
-	for i := range claims {
-		if claims[i].ClaimRef == claimRef {
-			claims[i].StatusMessage = status
-			claims[i].LastCheckedAt = time.Now().UTC().Format(time.RFC3339)
-			reg.Clusters[cluster] = claims
-			return true
+	if claim.StatusMessage != status || claim.Deleted {
+		claim.StatusMessage = status
+		claim.Deleted = false
+		claim.LastCheckedAt = now()
+	}
+	return true
+}
+
+// SetClaimConditions replaces the conditions of a claim and derives its Ready
+// and Synced flags from them. LastCheckedAt is only bumped when the
+// conditions actually change. Returns true if a matching entry was found.
+func SetClaimConditions(reg *RegistryFile, cluster, kind, claimRef string, conditions []Condition) bool {
+	claim := FindClaim(reg, cluster, kind, claimRef)
+	if claim == nil {
+		return false
+	}
+	ready := IsConditionTrue(conditions, "Ready")
+	synced := IsConditionTrue(conditions, "Synced")
+	if !slices.Equal(claim.Conditions, conditions) || claim.Ready == nil || *claim.Ready != ready || claim.Synced == nil || *claim.Synced != synced {
+		claim.Conditions = slices.Clone(conditions)
+		claim.Ready = &ready
+		claim.Synced = &synced
+		claim.LastCheckedAt = now()
+	}
+	return true
+}
+
+// SetClaimKind records the claim kind reported by the informer on a claim
+// whose kind is not recorded yet. Returns true if a matching entry was found.
+func SetClaimKind(reg *RegistryFile, cluster, kind, claimRef string) bool {
+	claim := FindClaim(reg, cluster, kind, claimRef)
+	if claim == nil {
+		return false
+	}
+	claim.Kind = kind
+	return true
+}
+
+// IsConditionTrue reports whether the condition of the given type has status
+// "True".
+func IsConditionTrue(conditions []Condition, condType string) bool {
+	for _, c := range conditions {
+		if c.Type == condType {
+			return c.Status == "True"
 		}
 	}
 	return false
 }
 
+// MarkClaimDeleted flags a claim as deleted while keeping it in the registry.
+// Returns true if a matching entry was found.
+func MarkClaimDeleted(reg *RegistryFile, cluster, kind, claimRef string) bool {
+	claim := FindClaim(reg, cluster, kind, claimRef)
+	if claim == nil {
+		return false
+	}
+	if !claim.Deleted {
+		claim.Deleted = true
+		claim.LastCheckedAt = now()
+	}
+	return true
+}
+
+// RemoveClaim drops a claim from the registry, removing the cluster key once
+// it has no claims left. Returns true if a matching entry was found.
+func RemoveClaim(reg *RegistryFile, cluster, kind, claimRef string) bool {
+	claims := reg.Clusters[cluster]
+	i := claimIndex(claims, kind, claimRef)
+	if i < 0 {
+		return false
+	}
+	claims = append(claims[:i], claims[i+1:]...)
+	if len(claims) == 0 {
+		delete(reg.Clusters, cluster)
+	} else {
+		reg.Clusters[cluster] = claims
+	}
+	return true
+}
+
+// TouchClaim bumps LastCheckedAt of a claim regardless of whether its status
+// changed. Returns true if a matching entry was found.
+func TouchClaim(reg *RegistryFile, cluster, kind, claimRef string) bool {
+	claim := FindClaim(reg, cluster, kind, claimRef)
+	if claim == nil {
+		return false
+	}
+	claim.LastCheckedAt = now()
+	return true
+}
+
+// AddClaim appends a new claim of the given kind for cluster, creating the
+// cluster key if it does not exist yet. Name and Namespace are derived from
+// claimRef, which is expected in "namespace/name" form. The added entry is
+// returned.
+func AddClaim(reg *RegistryFile, cluster, kind, claimRef, status string) ClaimEntry {
+	namespace, name := SplitClaimRef(claimRef)
+	entry := ClaimEntry{
+		Name:          name,
+		Namespace:     namespace,
+		ClaimRef:      claimRef,
+		Kind:          kind,
+		StatusMessage: status,
+		LastCheckedAt: now(),
+	}
+	reg.Clusters[cluster] = append(reg.Clusters[cluster], entry)
+	return entry
+}
+
+// SplitClaimRef splits a "namespace/name" claimRef at its last slash. A
+// claimRef without a slash is treated as a bare name.
+func SplitClaimRef(claimRef string) (namespace, name string) {
+	i := strings.LastIndex(claimRef, "/")
+	if i < 0 {
+		return "", claimRef
+	}
+	return claimRef[:i], claimRef[i+1:]
+}
+
+// FindClaim returns the claim of cluster identified by kind and claimRef, or
+// nil. See claimIndex for how kind is matched.
+func FindClaim(reg *RegistryFile, cluster, kind, claimRef string) *ClaimEntry {
+	claims := reg.Clusters[cluster]
+	if i := claimIndex(claims, kind, claimRef); i >= 0 {
+		return &claims[i]
+	}
+	return nil
+}
+
+// claimIndex returns the index of the claim with claimRef and kind, or -1.
+// Claims of different kinds may share a claimRef, so an exact match wins.
+// Otherwise an empty kind on either side, from an older registry file or an
+// agent that does not report kinds, matches any kind.
+func claimIndex(claims []ClaimEntry, kind, claimRef string) int {
+	fallback := -1
+	for i := range claims {
+		if claims[i].ClaimRef != claimRef {
+			continue
+		}
+		if claims[i].Kind == kind {
+			return i
+		}
+		if fallback < 0 && (kind == "" || claims[i].Kind == "") {
+			fallback = i
+		}
+	}
+	return fallback
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
 // SerializeRegistry marshals a RegistryFile back to YAML bytes.
 func SerializeRegistry(reg *RegistryFile) ([]byte, error) {
 	return yaml.Marshal(reg)
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -21,6 +22,17 @@ cluster-02:
     claimRef: postgresqls.2.2.2/web-db
     statusMessage: Ready
     lastCheckedAt: "2026-01-01T00:00:00Z"
+    ready: true
+    synced: true
+    conditions:
+      - type: Ready
+        status: "True"
+        reason: Available
+        lastTransitionTime: "2026-01-01T00:00:00Z"
+      - type: Synced
+        status: "True"
+        reason: ReconcileSuccess
+        lastTransitionTime: "2026-01-01T00:00:00Z"
 `
 
 func TestParseRegistry(t *testing.T) {
@@ -46,7 +58,7 @@ func TestUpdateClaimStatus_Existing(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	changed := UpdateClaimStatus(reg, "cluster-01", "postgresqls.2.2.2/my-db", "Degraded")
+	changed := UpdateClaimStatus(reg, "cluster-01", "", "postgresqls.2.2.2/my-db", "Degraded")
 	if !changed {
 		t.Fatal("expected UpdateClaimStatus to return true")
 	}
@@ -60,23 +72,215 @@ func TestUpdateClaimStatus_Existing(t *testing.T) {
 	}
 }
 
+func TestUpdateClaimStatus_Unchanged(t *testing.T) {
+	reg, err := ParseRegistry([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !UpdateClaimStatus(reg, "cluster-01", "", "postgresqls.2.2.2/my-db", "Ready") {
+		t.Fatal("expected UpdateClaimStatus to return true")
+	}
+
+	claim := reg.Clusters["cluster-01"][0]
+	if claim.LastCheckedAt != "2026-01-01T00:00:00Z" {
+		t.Errorf("expected LastCheckedAt to stay unchanged, got %q", claim.LastCheckedAt)
+	}
+}
+
+func TestTouchClaim(t *testing.T) {
+	reg, err := ParseRegistry([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !TouchClaim(reg, "cluster-01", "", "postgresqls.2.2.2/my-db") {
+		t.Fatal("expected TouchClaim to return true")
+	}
+	if reg.Clusters["cluster-01"][0].LastCheckedAt == "2026-01-01T00:00:00Z" {
+		t.Error("expected LastCheckedAt to be updated")
+	}
+	if TouchClaim(reg, "cluster-01", "", "nonexistent/claim") {
+		t.Fatal("expected TouchClaim to return false for non-existent claim")
+	}
+}
+
 func TestUpdateClaimStatus_NonExistent(t *testing.T) {
 	reg, err := ParseRegistry([]byte(sampleYAML))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	changed := UpdateClaimStatus(reg, "cluster-01", "nonexistent/claim", "Degraded")
+	changed := UpdateClaimStatus(reg, "cluster-01", "", "nonexistent/claim", "Degraded")
 	if changed {
 		t.Fatal("expected UpdateClaimStatus to return false for non-existent claim")
 	}
 
-	changed = UpdateClaimStatus(reg, "no-such-cluster", "postgresqls.2.2.2/my-db", "Degraded")
+	changed = UpdateClaimStatus(reg, "no-such-cluster", "", "postgresqls.2.2.2/my-db", "Degraded")
 	if changed {
 		t.Fatal("expected UpdateClaimStatus to return false for non-existent cluster")
 	}
 }
 
+func TestAddClaim(t *testing.T) {
+	reg, err := ParseRegistry([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := AddClaim(reg, "cluster-01", "", "apps/new-db", "Creating")
+	if entry.Name != "new-db" || entry.Namespace != "apps" {
+		t.Errorf("expected name 'new-db' in namespace 'apps', got %q in %q", entry.Name, entry.Namespace)
+	}
+	if entry.LastCheckedAt == "" {
+		t.Error("expected LastCheckedAt to be set")
+	}
+	if len(reg.Clusters["cluster-01"]) != 3 {
+		t.Fatalf("expected 3 claims in cluster-01, got %d", len(reg.Clusters["cluster-01"]))
+	}
+	if !UpdateClaimStatus(reg, "cluster-01", "", "apps/new-db", "Ready") {
+		t.Fatal("expected added claim to be found")
+	}
+
+	AddClaim(reg, "cluster-03", "", "standalone", "Ready")
+	claims, ok := reg.Clusters["cluster-03"]
+	if !ok || len(claims) != 1 {
+		t.Fatalf("expected cluster-03 to be created with 1 claim, got %v", claims)
+	}
+	if claims[0].Name != "standalone" || claims[0].Namespace != "" {
+		t.Errorf("unexpected name/namespace for bare claimRef: %q/%q", claims[0].Namespace, claims[0].Name)
+	}
+}
+
+func TestSetClaimConditions(t *testing.T) {
+	reg, err := ParseRegistry([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conditions := []Condition{
+		{Type: "Synced", Status: "True", Reason: "ReconcileSuccess"},
+		{Type: "Ready", Status: "False", Reason: "Creating", Message: "waiting for database"},
+	}
+	if !SetClaimConditions(reg, "cluster-01", "", "redis.3.0.0/my-cache", conditions) {
+		t.Fatal("expected SetClaimConditions to return true")
+	}
+
+	claim := reg.Clusters["cluster-01"][1]
+	if claim.Ready == nil || *claim.Ready {
+		t.Errorf("expected ready=false, got %v", claim.Ready)
+	}
+	if claim.Synced == nil || !*claim.Synced {
+		t.Errorf("expected synced=true, got %v", claim.Synced)
+	}
+	if !reflect.DeepEqual(claim.Conditions, conditions) {
+		t.Errorf("unexpected conditions: %+v", claim.Conditions)
+	}
+	if claim.LastCheckedAt == "2026-01-01T00:00:00Z" {
+		t.Error("expected LastCheckedAt to be updated")
+	}
+
+	// Reporting the same conditions again must not bump LastCheckedAt.
+	web := reg.Clusters["cluster-02"][0]
+	SetClaimConditions(reg, "cluster-02", "", "postgresqls.2.2.2/web-db", web.Conditions)
+	if got := reg.Clusters["cluster-02"][0].LastCheckedAt; got != "2026-01-01T00:00:00Z" {
+		t.Errorf("expected LastCheckedAt to stay unchanged, got %q", got)
+	}
+
+	if SetClaimConditions(reg, "cluster-01", "", "nonexistent/claim", conditions) {
+		t.Fatal("expected SetClaimConditions to return false for non-existent claim")
+	}
+}
+
+func TestMarkClaimDeleted(t *testing.T) {
+	reg, err := ParseRegistry([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !MarkClaimDeleted(reg, "cluster-01", "", "redis.3.0.0/my-cache") {
+		t.Fatal("expected MarkClaimDeleted to return true")
+	}
+	claim := reg.Clusters["cluster-01"][1]
+	if !claim.Deleted {
+		t.Fatal("expected claim to be marked as deleted")
+	}
+	if claim.LastCheckedAt == "2026-01-01T00:00:00Z" {
+		t.Error("expected LastCheckedAt to be updated")
+	}
+
+	// A status report for a recreated claim clears the deletion marker.
+	UpdateClaimStatus(reg, "cluster-01", "", "redis.3.0.0/my-cache", "Pending")
+	if reg.Clusters["cluster-01"][1].Deleted {
+		t.Fatal("expected UpdateClaimStatus to revive the claim")
+	}
+
+	if MarkClaimDeleted(reg, "cluster-01", "", "nonexistent/claim") {
+		t.Fatal("expected MarkClaimDeleted to return false for non-existent claim")
+	}
+}
+
+func TestRemoveClaim(t *testing.T) {
+	reg, err := ParseRegistry([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !RemoveClaim(reg, "cluster-01", "", "postgresqls.2.2.2/my-db") {
+		t.Fatal("expected RemoveClaim to return true")
+	}
+	claims := reg.Clusters["cluster-01"]
+	if len(claims) != 1 || claims[0].ClaimRef != "redis.3.0.0/my-cache" {
+		t.Fatalf("expected only my-cache to remain in cluster-01, got %+v", claims)
+	}
+
+	if !RemoveClaim(reg, "cluster-02", "", "postgresqls.2.2.2/web-db") {
+		t.Fatal("expected RemoveClaim to return true")
+	}
+	if _, ok := reg.Clusters["cluster-02"]; ok {
+		t.Fatal("expected empty cluster-02 to be removed")
+	}
+
+	if RemoveClaim(reg, "cluster-01", "", "nonexistent/claim") {
+		t.Fatal("expected RemoveClaim to return false for non-existent claim")
+	}
+}
+
+func TestFindClaim_Kind(t *testing.T) {
+	reg := &RegistryFile{Clusters: map[string][]ClaimEntry{
+		"cluster-01": {
+			{ClaimRef: "default/my-db", Kind: "PostgreSQL"},
+			{ClaimRef: "default/my-db", Kind: "Bucket"},
+			{ClaimRef: "default/legacy"},
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		kind     string
+		claimRef string
+		want     int
+	}{
+		{"exact kind", "Bucket", "default/my-db", 1},
+		{"other kind", "PostgreSQL", "default/my-db", 0},
+		{"unknown kind", "Redis", "default/my-db", -1},
+		{"no kind matches any", "", "default/my-db", 0},
+		{"claim without kind matches any", "Redis", "default/legacy", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindClaim(reg, "cluster-01", tt.kind, tt.claimRef)
+			claims := reg.Clusters["cluster-01"]
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Fatalf("expected no match, got %+v", *got)
+			case tt.want >= 0 && got != &claims[tt.want]:
+				t.Fatalf("expected claim %d, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestRoundTrip(t *testing.T) {
 	reg1, err := ParseRegistry([]byte(sampleYAML))
 	if err != nil {
@@ -106,7 +310,7 @@ func TestRoundTrip(t *testing.T) {
 			t.Fatalf("claim count mismatch for %q: %d vs %d", cluster, len(claims1), len(claims2))
 		}
 		for i := range claims1 {
-			if claims1[i] != claims2[i] {
+			if !reflect.DeepEqual(claims1[i], claims2[i]) {
 				t.Errorf("claim mismatch at %s[%d]: %+v vs %+v", cluster, i, claims1[i], claims2[i])
 			}
 		}
@@ -7,8 +7,24 @@ type ClaimEntry struct {
 	Name          string `yaml:"name"`
 	Namespace     string `yaml:"namespace"`
 	ClaimRef      string `yaml:"claimRef"`
+	Kind          string `yaml:"kind,omitempty"`
 	StatusMessage string `yaml:"statusMessage"`
 	LastCheckedAt string `yaml:"lastCheckedAt"`
+	Deleted       bool   `yaml:"deleted,omitempty"`
+	// Ready and Synced are derived from Conditions and unset for claims that
+	// were never reported with conditions.
+	Ready      *bool       `yaml:"ready,omitempty"`
+	Synced     *bool       `yaml:"synced,omitempty"`
+	Conditions []Condition `yaml:"conditions,omitempty"`
+}
+
+// Condition is a single entry of a claim's .status.conditions.
+type Condition struct {
+	Type               string `yaml:"type" json:"type"`
+	Status             string `yaml:"status" json:"status"`
+	Reason             string `yaml:"reason,omitempty" json:"reason,omitempty"`
+	Message            string `yaml:"message,omitempty" json:"message,omitempty"`
+	LastTransitionTime string `yaml:"lastTransitionTime,omitempty" json:"lastTransitionTime,omitempty"`
 }
 
 // RegistryFile holds the full registry: a mapping of cluster names to their claim entries.
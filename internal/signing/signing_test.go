@@ -0,0 +1,68 @@
+package signing
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignRequest_Verify(t *testing.T) {
+	secret := []byte("s3cr3t")
+	body := `{"cluster":"cluster-01"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/status", strings.NewReader(body))
+
+	if err := SignRequest(req, secret, time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("SignRequest failed: %v", err)
+	}
+	if got := req.Header.Get(HeaderTimestamp); got != "1700000000" {
+		t.Errorf("expected timestamp 1700000000, got %q", got)
+	}
+
+	// The body must still be readable after signing.
+	read, err := io.ReadAll(req.Body)
+	if err != nil || string(read) != body {
+		t.Fatalf("expected body to be preserved, got %q (%v)", read, err)
+	}
+
+	ts, nonce, sig := req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderNonce), req.Header.Get(HeaderSignature)
+	cases := []struct {
+		name   string
+		secret string
+		method string
+		path   string
+		body   string
+		want   bool
+	}{
+		{"valid", "s3cr3t", http.MethodPost, "/api/v1/status", body, true},
+		{"wrong secret", "other", http.MethodPost, "/api/v1/status", body, false},
+		{"other method", "s3cr3t", http.MethodDelete, "/api/v1/status", body, false},
+		{"other path", "s3cr3t", http.MethodPost, "/api/v1/status:batch", body, false},
+		{"tampered body", "s3cr3t", http.MethodPost, "/api/v1/status", `{"cluster":"cluster-02"}`, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Verify([]byte(tc.secret), tc.method, tc.path, ts, nonce, []byte(tc.body), sig)
+			if got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSignRequest_UniqueNonce(t *testing.T) {
+	a := httptest.NewRequest(http.MethodDelete, "/api/v1/status/c/ns/name", nil)
+	b := httptest.NewRequest(http.MethodDelete, "/api/v1/status/c/ns/name", nil)
+	now := time.Now()
+	if err := SignRequest(a, []byte("k"), now); err != nil {
+		t.Fatal(err)
+	}
+	if err := SignRequest(b, []byte("k"), now); err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.Get(HeaderNonce) == b.Header.Get(HeaderNonce) {
+		t.Error("expected a fresh nonce per request")
+	}
+}
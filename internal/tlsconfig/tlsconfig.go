@@ -0,0 +1,209 @@
+// Package tlsconfig builds TLS configurations for the collector and the
+// informer from PEM files on disk. Certificates and CA bundles are reloaded
+// when their files change, so certificates rotated by e.g. cert-manager are
+// picked up without a restart.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// NewServerConfig returns a TLS configuration serving the key pair in
+// certFile and keyFile. If clientCAFile is set, client certificates signed by
+// one of its CAs are requested and verified. They are optional at the TLS
+// layer so that probes and read-only clients keep working; callers enforce
+// them per route.
+func NewServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	keyPair, err := newKeyPairReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return keyPair.get(), nil
+		},
+	}
+	if clientCAFile == "" {
+		return cfg, nil
+	}
+
+	clientCAs, err := newCAReloader(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := cfg.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = clientCAs.get()
+		return c, nil
+	}
+	return cfg, nil
+}
+
+// NewClientConfig returns a TLS configuration for connecting to the
+// collector. If caFile is set, the server certificate is verified against its
+// CAs instead of the system roots. If certFile and keyFile are set, the key
+// pair is presented as client certificate.
+func NewClientConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		// The handshake reads RootCAs directly, so a rotated bundle would
+		// never be noticed. The certificate is verified against the
+		// reloaded bundle in VerifyConnection instead.
+		roots, err := newCAReloader(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyServer(cs, roots.get())
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		keyPair, err := newKeyPairReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return keyPair.get(), nil
+		}
+	}
+	return cfg, nil
+}
+
+// verifyServer verifies the server certificate of cs against roots and the
+// server name the client sent. Connections to IP addresses carry no server
+// name and are rejected, as their certificate cannot be matched.
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+	if cs.ServerName == "" {
+		return fmt.Errorf("verify server certificate: no server name, address the server by host name")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("verify server certificate: %w", err)
+	}
+	return nil
+}
+
+func newKeyPairReloader(certFile, keyFile string) (*reloader[*tls.Certificate], error) {
+	return newReloader([]string{certFile, keyFile}, func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load key pair: %w", err)
+		}
+		return &cert, nil
+	})
+}
+
+func newCAReloader(caFile string) (*reloader[*x509.CertPool], error) {
+	return newReloader([]string{caFile}, func() (*x509.CertPool, error) {
+		return loadCAPool(caFile)
+	})
+}
+
+func loadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// reloader caches a value loaded from files and loads it again once one of
+// their modification times changes.
+type reloader[T any] struct {
+	files []string
+	load  func() (T, error)
+
+	mu       sync.Mutex
+	value    T
+	modTimes []time.Time
+}
+
+func newReloader[T any](files []string, load func() (T, error)) (*reloader[T], error) {
+	r := &reloader[T]{files: files, load: load}
+	modTimes, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	r.value, r.modTimes = value, modTimes
+	return r, nil
+}
+
+// get returns the current value. If the files changed but can no longer be
+// loaded, e.g. because only the certificate of a pair has been replaced so
+// far, the previous value is kept and loading is retried on the next call.
+func (r *reloader[T]) get() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.stat()
+	if err != nil {
+		slog.Warn("stat TLS files, keeping loaded version", "files", r.files, "error", err)
+		return r.value
+	}
+	if r.unchanged(modTimes) {
+		return r.value
+	}
+
+	value, err := r.load()
+	if err != nil {
+		slog.Warn("reload TLS files, keeping loaded version", "files", r.files, "error", err)
+		return r.value
+	}
+	r.value, r.modTimes = value, modTimes
+	slog.Info("reloaded TLS files", "files", r.files)
+	return r.value
+}
+
+func (r *reloader[T]) stat() ([]time.Time, error) {
+	modTimes := make([]time.Time, len(r.files))
+	for i, f := range r.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", f, err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *reloader[T]) unchanged(modTimes []time.Time) bool {
+	for i, t := range modTimes {
+		if !t.Equal(r.modTimes[i]) {
+			return false
+		}
+	}
+	return true
+}
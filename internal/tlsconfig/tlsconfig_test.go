@@ -0,0 +1,254 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA: %v", err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for cn signed by the CA, and its key, to
+// certFile and keyFile.
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// writeFile writes data and moves the modification time forward, so a
+// rewrite within the file system's timestamp granularity is still noticed.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	} else {
+		modTime = time.Now()
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes %s: %v", path, err)
+	}
+}
+
+// newMTLSServer starts a server echoing the common name of the client
+// certificate, or "anonymous".
+func newMTLSServer(t *testing.T, ca *testCA, dir string) *httptest.Server {
+	t.Helper()
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	caFile := filepath.Join(dir, "client-ca.crt")
+	ca.issue(t, "collector", x509.ExtKeyUsageServerAuth, certFile, keyFile)
+	writeFile(t, caFile, ca.pem)
+
+	cfg, err := NewServerConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("NewServerConfig: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) == 0 {
+			io.WriteString(w, "anonymous")
+			return
+		}
+		io.WriteString(w, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serverURL returns the URL of srv by host name, which clients with a CA
+// bundle require.
+func serverURL(srv *httptest.Server) string {
+	return strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	srv := newMTLSServer(t, ca, dir)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	writeFile(t, caFile, ca.pem)
+	ca.issue(t, "cluster-a", x509.ExtKeyUsageClientAuth, certFile, keyFile)
+
+	cfg, err := NewClientConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("NewClientConfig: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+	if got := get(t, client, serverURL(srv)); got != "cluster-a" {
+		t.Fatalf("expected client certificate cluster-a, got %q", got)
+	}
+
+	// A rotated certificate is used for the next connection.
+	ca.issue(t, "cluster-b", x509.ExtKeyUsageClientAuth, certFile, keyFile)
+	if got := get(t, client, serverURL(srv)); got != "cluster-b" {
+		t.Fatalf("expected rotated certificate cluster-b, got %q", got)
+	}
+
+	// Without a client certificate the connection is accepted anonymously.
+	anonCfg, err := NewClientConfig("", "", caFile)
+	if err != nil {
+		t.Fatalf("NewClientConfig: %v", err)
+	}
+	anon := &http.Client{Transport: &http.Transport{TLSClientConfig: anonCfg}}
+	if got := get(t, anon, serverURL(srv)); got != "anonymous" {
+		t.Fatalf("expected anonymous, got %q", got)
+	}
+}
+
+func TestClientConfig_CARotation(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	srv := newMTLSServer(t, ca, dir)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+	cfg, err := NewClientConfig("", "", caFile)
+	if err != nil {
+		t.Fatalf("NewClientConfig: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+	if got := get(t, client, serverURL(srv)); got != "anonymous" {
+		t.Fatalf("expected anonymous, got %q", got)
+	}
+
+	// The server moves to a certificate of a new CA the client does not
+	// trust yet.
+	newCA := newTestCA(t)
+	newCA.issue(t, "collector", x509.ExtKeyUsageServerAuth, filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if _, err := client.Get(serverURL(srv)); err == nil {
+		t.Fatal("expected a certificate of an untrusted CA to be rejected")
+	}
+
+	// The rotated bundle is used for the next connection.
+	writeFile(t, caFile, newCA.pem)
+	if got := get(t, client, serverURL(srv)); got != "anonymous" {
+		t.Fatalf("expected anonymous after CA rotation, got %q", got)
+	}
+
+	// IP addresses carry no server name to verify the certificate against.
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("expected a connection by IP address to be rejected")
+	}
+}
+
+func TestReloader_KeepsLastGoodVersion(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	ca.issue(t, "cluster-a", x509.ExtKeyUsageClientAuth, certFile, keyFile)
+
+	r, err := newKeyPairReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newKeyPairReloader: %v", err)
+	}
+	first := r.get()
+
+	// Only the certificate has been replaced so far; it does not match the
+	// key yet.
+	otherDir := t.TempDir()
+	ca.issue(t, "cluster-b", x509.ExtKeyUsageClientAuth, filepath.Join(otherDir, "tls.crt"), filepath.Join(otherDir, "tls.key"))
+	data, err := os.ReadFile(filepath.Join(otherDir, "tls.crt"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	writeFile(t, certFile, data)
+
+	if got := r.get(); got != first {
+		t.Fatal("expected mismatched key pair to keep the loaded certificate")
+	}
+}
+
+func TestNewClientConfig_Errors(t *testing.T) {
+	if _, err := NewClientConfig("tls.crt", "", ""); err == nil {
+		t.Error("expected error for certificate without key")
+	}
+	if _, err := NewClientConfig("", "", filepath.Join(t.TempDir(), "missing.crt")); err == nil {
+		t.Error("expected error for missing CA bundle")
+	}
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "op")
+	span.End()
+
+	traceParent := TraceParent(ctx)
+	if traceParent == "" {
+		t.Fatal("expected a traceparent for a recording span")
+	}
+
+	parent := trace.SpanContextFromContext(ContextWithTraceParent(context.Background(), traceParent))
+	if parent.TraceID() != span.SpanContext().TraceID() || parent.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected parent %s, got %s/%s", traceParent, parent.TraceID(), parent.SpanID())
+	}
+
+	link, ok := Link(traceParent, attribute.String("claim.ref", "default/my-db"))
+	if !ok {
+		t.Fatal("expected a link")
+	}
+	if link.SpanContext.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected link to span %s, got %s", span.SpanContext().SpanID(), link.SpanContext.SpanID())
+	}
+	if len(link.Attributes) != 1 {
+		t.Errorf("expected link attributes to be kept, got %v", link.Attributes)
+	}
+}
+
+func TestTraceParent_NoSpan(t *testing.T) {
+	if got := TraceParent(context.Background()); got != "" {
+		t.Errorf("expected empty traceparent, got %q", got)
+	}
+	for _, tp := range []string{"", "not-a-traceparent"} {
+		if _, ok := Link(tp); ok {
+			t.Errorf("expected no link for %q", tp)
+		}
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), "test", "jaeger"); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}